@@ -4,6 +4,7 @@
 package abi
 
 import (
+	"errors"
 	"math/big"
 	"strings"
 
@@ -15,8 +16,26 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 )
 
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// PxcMetaData contains all meta data concerning the Pxc contract.
+var PxcMetaData = &bind.MetaData{
+	ABI: "[{\"constant\":true,\"inputs\":[],\"name\":\"name\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_spender\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"approve\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"totalSupply\",\"outputs\":[{\"name\":\"totalsupply\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_from\",\"type\":\"address\"},{\"name\":\"_to\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"transferFrom\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"getAddress\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"}],\"name\":\"balanceOf\",\"outputs\":[{\"name\":\"balance\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"symbol\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_to\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"transfer\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"assuer\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"},{\"name\":\"_spender\",\"type\":\"address\"}],\"name\":\"allowance\",\"outputs\":[{\"name\":\"remaining\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"fundation\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"totalSupply\",\"type\":\"uint256\"},{\"name\":\"_owner\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_from\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_to\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"Transfer\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_owner\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_spender\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"Approval\",\"type\":\"event\"}]",
+}
+
 // PxcABI is the input ABI used to generate the binding from.
-const PxcABI = "[{\"constant\":true,\"inputs\":[],\"name\":\"name\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_spender\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"approve\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"totalSupply\",\"outputs\":[{\"name\":\"totalsupply\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_from\",\"type\":\"address\"},{\"name\":\"_to\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"transferFrom\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"getAddress\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"}],\"name\":\"balanceOf\",\"outputs\":[{\"name\":\"balance\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"symbol\",\"outputs\":[{\"name\":\"\",\"type\":\"string\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"_to\",\"type\":\"address\"},{\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"transfer\",\"outputs\":[{\"name\":\"success\",\"type\":\"bool\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"assuer\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"_owner\",\"type\":\"address\"},{\"name\":\"_spender\",\"type\":\"address\"}],\"name\":\"allowance\",\"outputs\":[{\"name\":\"remaining\",\"type\":\"uint256\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"constant\":true,\"inputs\":[],\"name\":\"fundation\",\"outputs\":[{\"name\":\"\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"totalSupply\",\"type\":\"uint256\"},{\"name\":\"_owner\",\"type\":\"address\"}],\"payable\":false,\"stateMutability\":\"nonpayable\",\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_from\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_to\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"Transfer\",\"type\":\"event\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"_owner\",\"type\":\"address\"},{\"indexed\":true,\"name\":\"_spender\",\"type\":\"address\"},{\"indexed\":false,\"name\":\"_value\",\"type\":\"uint256\"}],\"name\":\"Approval\",\"type\":\"event\"}]"
+// Deprecated: Use PxcMetaData.ABI instead.
+var PxcABI = PxcMetaData.ABI
 
 // Pxc is an auto generated Go binding around an Ethereum contract.
 type Pxc struct {
@@ -126,7 +145,7 @@ func bindPxc(address common.Address, caller bind.ContractCaller, transactor bind
 // sets the output to result. The result type might be a single field for simple
 // returns, a slice of interfaces for anonymous returns and a struct for named
 // returns.
-func (_Pxc *PxcRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+func (_Pxc *PxcRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
 	return _Pxc.Contract.PxcCaller.contract.Call(opts, result, method, params...)
 }
 
@@ -145,7 +164,7 @@ func (_Pxc *PxcRaw) Transact(opts *bind.TransactOpts, method string, params ...i
 // sets the output to result. The result type might be a single field for simple
 // returns, a slice of interfaces for anonymous returns and a struct for named
 // returns.
-func (_Pxc *PxcCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+func (_Pxc *PxcCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
 	return _Pxc.Contract.contract.Call(opts, result, method, params...)
 }
 
@@ -162,271 +181,311 @@ func (_Pxc *PxcTransactorRaw) Transact(opts *bind.TransactOpts, method string, p
 
 // Allowance is a free data retrieval call binding the contract method 0xdd62ed3e.
 //
-// Solidity: function allowance(_owner address, _spender address) constant returns(remaining uint256)
+// Solidity: function allowance(address _owner, address _spender) view returns(uint256 remaining)
 func (_Pxc *PxcCaller) Allowance(opts *bind.CallOpts, _owner common.Address, _spender common.Address) (*big.Int, error) {
-	var (
-		ret0 = new(*big.Int)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "allowance", _owner, _spender)
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "allowance", _owner, _spender)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
 }
 
 // Allowance is a free data retrieval call binding the contract method 0xdd62ed3e.
 //
-// Solidity: function allowance(_owner address, _spender address) constant returns(remaining uint256)
+// Solidity: function allowance(address _owner, address _spender) view returns(uint256 remaining)
 func (_Pxc *PxcSession) Allowance(_owner common.Address, _spender common.Address) (*big.Int, error) {
 	return _Pxc.Contract.Allowance(&_Pxc.CallOpts, _owner, _spender)
 }
 
 // Allowance is a free data retrieval call binding the contract method 0xdd62ed3e.
 //
-// Solidity: function allowance(_owner address, _spender address) constant returns(remaining uint256)
+// Solidity: function allowance(address _owner, address _spender) view returns(uint256 remaining)
 func (_Pxc *PxcCallerSession) Allowance(_owner common.Address, _spender common.Address) (*big.Int, error) {
 	return _Pxc.Contract.Allowance(&_Pxc.CallOpts, _owner, _spender)
 }
 
 // Assuer is a free data retrieval call binding the contract method 0xd6a6ec56.
 //
-// Solidity: function assuer() constant returns(address)
+// Solidity: function assuer() view returns(address)
 func (_Pxc *PxcCaller) Assuer(opts *bind.CallOpts) (common.Address, error) {
-	var (
-		ret0 = new(common.Address)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "assuer")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "assuer")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
 }
 
 // Assuer is a free data retrieval call binding the contract method 0xd6a6ec56.
 //
-// Solidity: function assuer() constant returns(address)
+// Solidity: function assuer() view returns(address)
 func (_Pxc *PxcSession) Assuer() (common.Address, error) {
 	return _Pxc.Contract.Assuer(&_Pxc.CallOpts)
 }
 
 // Assuer is a free data retrieval call binding the contract method 0xd6a6ec56.
 //
-// Solidity: function assuer() constant returns(address)
+// Solidity: function assuer() view returns(address)
 func (_Pxc *PxcCallerSession) Assuer() (common.Address, error) {
 	return _Pxc.Contract.Assuer(&_Pxc.CallOpts)
 }
 
 // BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
 //
-// Solidity: function balanceOf(_owner address) constant returns(balance uint256)
+// Solidity: function balanceOf(address _owner) view returns(uint256 balance)
 func (_Pxc *PxcCaller) BalanceOf(opts *bind.CallOpts, _owner common.Address) (*big.Int, error) {
-	var (
-		ret0 = new(*big.Int)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "balanceOf", _owner)
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "balanceOf", _owner)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
 }
 
 // BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
 //
-// Solidity: function balanceOf(_owner address) constant returns(balance uint256)
+// Solidity: function balanceOf(address _owner) view returns(uint256 balance)
 func (_Pxc *PxcSession) BalanceOf(_owner common.Address) (*big.Int, error) {
 	return _Pxc.Contract.BalanceOf(&_Pxc.CallOpts, _owner)
 }
 
 // BalanceOf is a free data retrieval call binding the contract method 0x70a08231.
 //
-// Solidity: function balanceOf(_owner address) constant returns(balance uint256)
+// Solidity: function balanceOf(address _owner) view returns(uint256 balance)
 func (_Pxc *PxcCallerSession) BalanceOf(_owner common.Address) (*big.Int, error) {
 	return _Pxc.Contract.BalanceOf(&_Pxc.CallOpts, _owner)
 }
 
 // Fundation is a free data retrieval call binding the contract method 0xe0a9be75.
 //
-// Solidity: function fundation() constant returns(address)
+// Solidity: function fundation() view returns(address)
 func (_Pxc *PxcCaller) Fundation(opts *bind.CallOpts) (common.Address, error) {
-	var (
-		ret0 = new(common.Address)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "fundation")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "fundation")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
 }
 
 // Fundation is a free data retrieval call binding the contract method 0xe0a9be75.
 //
-// Solidity: function fundation() constant returns(address)
+// Solidity: function fundation() view returns(address)
 func (_Pxc *PxcSession) Fundation() (common.Address, error) {
 	return _Pxc.Contract.Fundation(&_Pxc.CallOpts)
 }
 
 // Fundation is a free data retrieval call binding the contract method 0xe0a9be75.
 //
-// Solidity: function fundation() constant returns(address)
+// Solidity: function fundation() view returns(address)
 func (_Pxc *PxcCallerSession) Fundation() (common.Address, error) {
 	return _Pxc.Contract.Fundation(&_Pxc.CallOpts)
 }
 
 // GetAddress is a free data retrieval call binding the contract method 0x38cc4831.
 //
-// Solidity: function getAddress() constant returns(address)
+// Solidity: function getAddress() view returns(address)
 func (_Pxc *PxcCaller) GetAddress(opts *bind.CallOpts) (common.Address, error) {
-	var (
-		ret0 = new(common.Address)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "getAddress")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "getAddress")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
 }
 
 // GetAddress is a free data retrieval call binding the contract method 0x38cc4831.
 //
-// Solidity: function getAddress() constant returns(address)
+// Solidity: function getAddress() view returns(address)
 func (_Pxc *PxcSession) GetAddress() (common.Address, error) {
 	return _Pxc.Contract.GetAddress(&_Pxc.CallOpts)
 }
 
 // GetAddress is a free data retrieval call binding the contract method 0x38cc4831.
 //
-// Solidity: function getAddress() constant returns(address)
+// Solidity: function getAddress() view returns(address)
 func (_Pxc *PxcCallerSession) GetAddress() (common.Address, error) {
 	return _Pxc.Contract.GetAddress(&_Pxc.CallOpts)
 }
 
 // Name is a free data retrieval call binding the contract method 0x06fdde03.
 //
-// Solidity: function name() constant returns(string)
+// Solidity: function name() view returns(string)
 func (_Pxc *PxcCaller) Name(opts *bind.CallOpts) (string, error) {
-	var (
-		ret0 = new(string)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "name")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "name")
+
+	if err != nil {
+		return *new(string), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(string)).(*string)
+
+	return out0, err
+
 }
 
 // Name is a free data retrieval call binding the contract method 0x06fdde03.
 //
-// Solidity: function name() constant returns(string)
+// Solidity: function name() view returns(string)
 func (_Pxc *PxcSession) Name() (string, error) {
 	return _Pxc.Contract.Name(&_Pxc.CallOpts)
 }
 
 // Name is a free data retrieval call binding the contract method 0x06fdde03.
 //
-// Solidity: function name() constant returns(string)
+// Solidity: function name() view returns(string)
 func (_Pxc *PxcCallerSession) Name() (string, error) {
 	return _Pxc.Contract.Name(&_Pxc.CallOpts)
 }
 
 // Symbol is a free data retrieval call binding the contract method 0x95d89b41.
 //
-// Solidity: function symbol() constant returns(string)
+// Solidity: function symbol() view returns(string)
 func (_Pxc *PxcCaller) Symbol(opts *bind.CallOpts) (string, error) {
-	var (
-		ret0 = new(string)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "symbol")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "symbol")
+
+	if err != nil {
+		return *new(string), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(string)).(*string)
+
+	return out0, err
+
 }
 
 // Symbol is a free data retrieval call binding the contract method 0x95d89b41.
 //
-// Solidity: function symbol() constant returns(string)
+// Solidity: function symbol() view returns(string)
 func (_Pxc *PxcSession) Symbol() (string, error) {
 	return _Pxc.Contract.Symbol(&_Pxc.CallOpts)
 }
 
 // Symbol is a free data retrieval call binding the contract method 0x95d89b41.
 //
-// Solidity: function symbol() constant returns(string)
+// Solidity: function symbol() view returns(string)
 func (_Pxc *PxcCallerSession) Symbol() (string, error) {
 	return _Pxc.Contract.Symbol(&_Pxc.CallOpts)
 }
 
 // TotalSupply is a free data retrieval call binding the contract method 0x18160ddd.
 //
-// Solidity: function totalSupply() constant returns(totalsupply uint256)
+// Solidity: function totalSupply() view returns(uint256 totalsupply)
 func (_Pxc *PxcCaller) TotalSupply(opts *bind.CallOpts) (*big.Int, error) {
-	var (
-		ret0 = new(*big.Int)
-	)
-	out := ret0
-	err := _Pxc.contract.Call(opts, out, "totalSupply")
-	return *ret0, err
+	var out []interface{}
+	err := _Pxc.contract.Call(opts, &out, "totalSupply")
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
 }
 
 // TotalSupply is a free data retrieval call binding the contract method 0x18160ddd.
 //
-// Solidity: function totalSupply() constant returns(totalsupply uint256)
+// Solidity: function totalSupply() view returns(uint256 totalsupply)
 func (_Pxc *PxcSession) TotalSupply() (*big.Int, error) {
 	return _Pxc.Contract.TotalSupply(&_Pxc.CallOpts)
 }
 
 // TotalSupply is a free data retrieval call binding the contract method 0x18160ddd.
 //
-// Solidity: function totalSupply() constant returns(totalsupply uint256)
+// Solidity: function totalSupply() view returns(uint256 totalsupply)
 func (_Pxc *PxcCallerSession) TotalSupply() (*big.Int, error) {
 	return _Pxc.Contract.TotalSupply(&_Pxc.CallOpts)
 }
 
 // Approve is a paid mutator transaction binding the contract method 0x095ea7b3.
 //
-// Solidity: function approve(_spender address, _value uint256) returns(success bool)
+// Solidity: function approve(address _spender, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactor) Approve(opts *bind.TransactOpts, _spender common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.contract.Transact(opts, "approve", _spender, _value)
 }
 
 // Approve is a paid mutator transaction binding the contract method 0x095ea7b3.
 //
-// Solidity: function approve(_spender address, _value uint256) returns(success bool)
+// Solidity: function approve(address _spender, uint256 _value) returns(bool success)
 func (_Pxc *PxcSession) Approve(_spender common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.Approve(&_Pxc.TransactOpts, _spender, _value)
 }
 
 // Approve is a paid mutator transaction binding the contract method 0x095ea7b3.
 //
-// Solidity: function approve(_spender address, _value uint256) returns(success bool)
+// Solidity: function approve(address _spender, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactorSession) Approve(_spender common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.Approve(&_Pxc.TransactOpts, _spender, _value)
 }
 
 // Transfer is a paid mutator transaction binding the contract method 0xa9059cbb.
 //
-// Solidity: function transfer(_to address, _value uint256) returns(success bool)
+// Solidity: function transfer(address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactor) Transfer(opts *bind.TransactOpts, _to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.contract.Transact(opts, "transfer", _to, _value)
 }
 
 // Transfer is a paid mutator transaction binding the contract method 0xa9059cbb.
 //
-// Solidity: function transfer(_to address, _value uint256) returns(success bool)
+// Solidity: function transfer(address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcSession) Transfer(_to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.Transfer(&_Pxc.TransactOpts, _to, _value)
 }
 
 // Transfer is a paid mutator transaction binding the contract method 0xa9059cbb.
 //
-// Solidity: function transfer(_to address, _value uint256) returns(success bool)
+// Solidity: function transfer(address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactorSession) Transfer(_to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.Transfer(&_Pxc.TransactOpts, _to, _value)
 }
 
 // TransferFrom is a paid mutator transaction binding the contract method 0x23b872dd.
 //
-// Solidity: function transferFrom(_from address, _to address, _value uint256) returns(success bool)
+// Solidity: function transferFrom(address _from, address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactor) TransferFrom(opts *bind.TransactOpts, _from common.Address, _to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.contract.Transact(opts, "transferFrom", _from, _to, _value)
 }
 
 // TransferFrom is a paid mutator transaction binding the contract method 0x23b872dd.
 //
-// Solidity: function transferFrom(_from address, _to address, _value uint256) returns(success bool)
+// Solidity: function transferFrom(address _from, address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcSession) TransferFrom(_from common.Address, _to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.TransferFrom(&_Pxc.TransactOpts, _from, _to, _value)
 }
 
 // TransferFrom is a paid mutator transaction binding the contract method 0x23b872dd.
 //
-// Solidity: function transferFrom(_from address, _to address, _value uint256) returns(success bool)
+// Solidity: function transferFrom(address _from, address _to, uint256 _value) returns(bool success)
 func (_Pxc *PxcTransactorSession) TransferFrom(_from common.Address, _to common.Address, _value *big.Int) (*types.Transaction, error) {
 	return _Pxc.Contract.TransferFrom(&_Pxc.TransactOpts, _from, _to, _value)
 }
@@ -508,7 +567,7 @@ type PxcApproval struct {
 
 // FilterApproval is a free log retrieval operation binding the contract event 0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925.
 //
-// Solidity: event Approval(_owner indexed address, _spender indexed address, _value uint256)
+// Solidity: event Approval(address indexed _owner, address indexed _spender, uint256 _value)
 func (_Pxc *PxcFilterer) FilterApproval(opts *bind.FilterOpts, _owner []common.Address, _spender []common.Address) (*PxcApprovalIterator, error) {
 
 	var _ownerRule []interface{}
@@ -529,7 +588,7 @@ func (_Pxc *PxcFilterer) FilterApproval(opts *bind.FilterOpts, _owner []common.A
 
 // WatchApproval is a free log subscription operation binding the contract event 0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925.
 //
-// Solidity: event Approval(_owner indexed address, _spender indexed address, _value uint256)
+// Solidity: event Approval(address indexed _owner, address indexed _spender, uint256 _value)
 func (_Pxc *PxcFilterer) WatchApproval(opts *bind.WatchOpts, sink chan<- *PxcApproval, _owner []common.Address, _spender []common.Address) (event.Subscription, error) {
 
 	var _ownerRule []interface{}
@@ -573,6 +632,18 @@ func (_Pxc *PxcFilterer) WatchApproval(opts *bind.WatchOpts, sink chan<- *PxcApp
 	}), nil
 }
 
+// ParseApproval is a log parse operation binding the contract event 0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925.
+//
+// Solidity: event Approval(address indexed _owner, address indexed _spender, uint256 _value)
+func (_Pxc *PxcFilterer) ParseApproval(log types.Log) (*PxcApproval, error) {
+	event := new(PxcApproval)
+	if err := _Pxc.contract.UnpackLog(event, "Approval", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
 // PxcTransferIterator is returned from FilterTransfer and is used to iterate over the raw logs and unpacked data for Transfer events raised by the Pxc contract.
 type PxcTransferIterator struct {
 	Event *PxcTransfer // Event containing the contract specifics and raw log
@@ -650,7 +721,7 @@ type PxcTransfer struct {
 
 // FilterTransfer is a free log retrieval operation binding the contract event 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef.
 //
-// Solidity: event Transfer(_from indexed address, _to indexed address, _value uint256)
+// Solidity: event Transfer(address indexed _from, address indexed _to, uint256 _value)
 func (_Pxc *PxcFilterer) FilterTransfer(opts *bind.FilterOpts, _from []common.Address, _to []common.Address) (*PxcTransferIterator, error) {
 
 	var _fromRule []interface{}
@@ -671,7 +742,7 @@ func (_Pxc *PxcFilterer) FilterTransfer(opts *bind.FilterOpts, _from []common.Ad
 
 // WatchTransfer is a free log subscription operation binding the contract event 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef.
 //
-// Solidity: event Transfer(_from indexed address, _to indexed address, _value uint256)
+// Solidity: event Transfer(address indexed _from, address indexed _to, uint256 _value)
 func (_Pxc *PxcFilterer) WatchTransfer(opts *bind.WatchOpts, sink chan<- *PxcTransfer, _from []common.Address, _to []common.Address) (event.Subscription, error) {
 
 	var _fromRule []interface{}
@@ -714,3 +785,15 @@ func (_Pxc *PxcFilterer) WatchTransfer(opts *bind.WatchOpts, sink chan<- *PxcTra
 		}
 	}), nil
 }
+
+// ParseTransfer is a log parse operation binding the contract event 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef.
+//
+// Solidity: event Transfer(address indexed _from, address indexed _to, uint256 _value)
+func (_Pxc *PxcFilterer) ParseTransfer(log types.Log) (*PxcTransfer, error) {
+	event := new(PxcTransfer)
+	if err := _Pxc.contract.UnpackLog(event, "Transfer", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
@@ -0,0 +1,259 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"wallet/keystorecode"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AgentSockEnv is the environment variable holding the agent's unix socket
+const AgentSockEnv = "WALLET_AGENT_SOCK"
+
+// AgentAccount is the unlock state of an account held by the agent
+type AgentAccount struct {
+	Address  common.Address `json:"address"`
+	Unlocked bool           `json:"unlocked"`
+	Expires  *time.Time     `json:"expires,omitempty"`
+}
+
+// AgentAPI is served in the "agent" namespace by the agent
+type AgentAPI struct {
+	ks      *keystorecode.KeyStore
+	mu      sync.Mutex
+	expires map[common.Address]time.Time // zero time: unlocked until the agent exits
+}
+
+// Unlock unlocks the account for seconds, 0 keeps it unlocked until the agent exits
+func (api *AgentAPI) Unlock(addr common.Address, password string, seconds uint64) error {
+	timeout := time.Duration(seconds) * time.Second
+	if err := api.ks.TimedUnlock(accounts.Account{Address: addr}, password, timeout); err != nil {
+		return err
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	// like the keystore, an indefinite unlock is not shortened
+	if expires, ok := api.expires[addr]; ok && expires.IsZero() {
+		return nil
+	}
+	if timeout > 0 {
+		api.expires[addr] = time.Now().Add(timeout)
+	} else {
+		api.expires[addr] = time.Time{}
+	}
+	log.Printf("unlocked %s for %v\n", addr.Hex(), timeout)
+	return nil
+}
+
+// Lock wipes the key of addr, or of all accounts when addr is nil
+func (api *AgentAPI) Lock(addr *common.Address) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	for unlocked := range api.expires {
+		if addr == nil || *addr == unlocked {
+			api.ks.Lock(unlocked)
+			delete(api.expires, unlocked)
+			log.Printf("locked %s\n", unlocked.Hex())
+		}
+	}
+	return nil
+}
+
+// Status lists the accounts of the wallet and whether they are unlocked
+func (api *AgentAPI) Status() []AgentAccount {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	now := time.Now()
+	status := []AgentAccount{}
+	for _, a := range api.ks.Accounts() {
+		acc := AgentAccount{Address: a.Address}
+		if expires, ok := api.expires[a.Address]; ok {
+			// the keystore wipes the key itself once the timeout passed
+			if expires.IsZero() {
+				acc.Unlocked = true
+			} else if expires.After(now) {
+				acc.Unlocked = true
+				acc.Expires = &expires
+			} else {
+				delete(api.expires, a.Address)
+			}
+		}
+		status = append(status, acc)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Address.Hex() < status[j].Address.Hex() })
+	return status
+}
+
+// RunAgent serves the agent_* and account_* api of the wallet on socket
+// until it is interrupted, all keys are locked on exit
+func (cli *CLI) RunAgent(name, socket string) error {
+	ks, err := cli.openKeyStore(name)
+	if err != nil {
+		return err
+	}
+	agent := &AgentAPI{ks: ks, expires: make(map[common.Address]time.Time)}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("agent", agent); err != nil {
+		return err
+	}
+	// signing requests of unlocked accounts need no further approval, so
+	// only the transactions of the CLI are signed
+	if err := server.RegisterName("account", &agentSigner{api: &SignerAPI{ks: ks}}); err != nil {
+		return err
+	}
+
+	listener, err := listenUnix(socket)
+	if err != nil {
+		return err
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		agent.Lock(nil)
+		listener.Close()
+	}()
+
+	fmt.Printf("%s=%s; export %s;\n", AgentSockEnv, socket, AgentSockEnv)
+	log.Printf("agent of wallet %s listening on %s\n", name, socket)
+	server.ServeListener(listener)
+	os.Remove(socket)
+	return nil
+}
+
+// agentSigner is the part of the account api served by the agent: the
+// transactions of the CLI. Messages and typed data, e.g. token permits, are
+// signed by the signer daemon, which asks for approval.
+type agentSigner struct {
+	api *SignerAPI
+}
+
+// SignTransaction signs the transaction with an unlocked account
+func (a *agentSigner) SignTransaction(ctx context.Context, args SendTxArgs) (*SignTxResult, error) {
+	return a.api.SignTransaction(ctx, args)
+}
+
+// listenUnix listens on the unix socket path, readable by its owner only.
+// The socket is created with 0600 in a new 0700 directory and then moved to
+// path, so that no other user can connect before its permissions are set.
+func listenUnix(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".socket")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by its path, not by the temporary one
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	os.Remove(path)
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// dialAgent connects to the agent named by WALLET_AGENT_SOCK
+func dialAgent() (*rpc.Client, error) {
+	socket := os.Getenv(AgentSockEnv)
+	if socket == "" {
+		return nil, fmt.Errorf("%s is not set, start an agent first", AgentSockEnv)
+	}
+	return rpc.Dial(socket)
+}
+
+// AgentUnlock asks the agent to unlock addr for the duration
+func (cli *CLI) AgentUnlock(addr, pass string, duration time.Duration) error {
+	client, err := dialAgent()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Call(nil, "agent_unlock", common.HexToAddress(addr), pass, uint64(duration/time.Second))
+}
+
+// AgentLock asks the agent to lock addr, or every account if addr is empty
+func (cli *CLI) AgentLock(addr string) error {
+	client, err := dialAgent()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if addr == "" {
+		return client.Call(nil, "agent_lock", nil)
+	}
+	return client.Call(nil, "agent_lock", common.HexToAddress(addr))
+}
+
+// AgentStatus prints the accounts held by the agent
+func (cli *CLI) AgentStatus() error {
+	client, err := dialAgent()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var status []AgentAccount
+	if err := client.Call(&status, "agent_status"); err != nil {
+		return err
+	}
+	for _, acc := range status {
+		switch {
+		case !acc.Unlocked:
+			fmt.Printf("%s locked\n", acc.Address.Hex())
+		case acc.Expires == nil:
+			fmt.Printf("%s unlocked\n", acc.Address.Hex())
+		default:
+			fmt.Printf("%s unlocked for %v\n", acc.Address.Hex(), time.Until(*acc.Expires).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// agentUnlocked returns the agent socket if the agent holds addr unlocked
+func agentUnlocked(addr common.Address) (string, bool) {
+	client, err := dialAgent()
+	if err != nil {
+		return "", false
+	}
+	defer client.Close()
+
+	var status []AgentAccount
+	if err := client.Call(&status, "agent_status"); err != nil {
+		log.Println("failed to get agent status:", err)
+		return "", false
+	}
+	for _, acc := range status {
+		if acc.Address == addr && acc.Unlocked {
+			return os.Getenv(AgentSockEnv), true
+		}
+	}
+	return "", false
+}
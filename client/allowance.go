@@ -0,0 +1,329 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"wallet/abi"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+/*
+授权: ERC-20 的 approve 允许 spender(如交易所合约) 用 transferFrom 转走 owner 的 token, 最多为授权的数量.
+approve -value max 为无限授权(2^256-1), revoke 把授权改为 0.
+allowances 从注册 token 的 Approval 事件找出钱包账户授权过的 spender, 列出仍然有效的授权.
+*/
+
+// approvalLogBlocks is the number of blocks of Approval logs requested at
+// once, nodes limit the block range of eth_getLogs
+const approvalLogBlocks = 10000
+
+// Approval is an outstanding allowance of a token given by an account of
+// the wallet
+type Approval struct {
+	Token   string `json:"token"`
+	Address string `json:"address"`
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	// Allowance is in token units, "unlimited" for 2^256-1
+	Allowance string `json:"allowance"`
+	// Block and Tx are of the last Approval event of the allowance
+	Block uint64 `json:"block"`
+	Tx    string `json:"tx"`
+}
+
+// parseAllowance parses an allowance in units of token, max or unlimited
+// for 2^256-1 that most tokens never decrease
+func parseAllowance(s string, token *TokenMeta) (*big.Int, error) {
+	switch strings.ToLower(s) {
+	case "max", "unlimited":
+		return new(big.Int).Set(math.MaxBig256), nil
+	}
+	return token.Parse(s)
+}
+
+// formatAllowance formats an allowance in units of token
+func formatAllowance(amount *big.Int, token *TokenMeta) string {
+	if amount.Cmp(math.MaxBig256) == 0 {
+		return "unlimited " + token.Symbol
+	}
+	return token.Format(amount)
+}
+
+// tokenAllowance returns the amount of token of owner that spender can
+// transfer with the generic ERC-20 binding
+func tokenAllowance(ctx context.Context, caller bind.ContractCaller, token, owner, spender common.Address) (*big.Int, error) {
+	erc20, err := abi.NewERC20Caller(token, caller)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.Allowance(&bind.CallOpts{Context: ctx}, owner, spender)
+}
+
+// sendTokenCall sends a call of method of token with args from the account
+// from, after confirmation, and waits for confirmations blocks when not 0
+func (cli *CLI) sendTokenCall(ctx context.Context, from common.Address, token *TokenMeta, method string, opts TxOptions, yes bool, confirmations uint64, args ...interface{}) error {
+	data, err := erc20ABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	rclient, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	msg := ethereum.CallMsg{From: from, To: &token.Address, Data: data}
+	tx, err := cli.sendMsg(ctx, rclient, msg, opts, yes, erc20CallSummary(token.Address, data, token.Symbol, &token.Decimals))
+	if err != nil {
+		return err
+	}
+	log.Printf("from: %s %s of %s sent: %s\n", from.Hex(), method, token.Symbol, tx.Hash().Hex())
+	cli.logExplorer(tx.Hash())
+	if confirmations > 0 {
+		return cli.waitAndReport(ctx, ethclient.NewClient(rclient), tx, confirmations)
+	}
+	return nil
+}
+
+// Approve allows spender to transfer amount of the token symbol of from,
+// in token units or max for an unlimited allowance
+func (cli *CLI) Approve(from, symbol, spender, amount string, opts TxOptions, yes bool, confirmations uint64) error {
+	ctx := context.Background()
+	token, err := cli.lookupToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	value, err := parseAllowance(amount, token)
+	if err != nil {
+		return err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	owner, spenderAddr := common.HexToAddress(from), common.HexToAddress(spender)
+	current, err := tokenAllowance(ctx, client, token.Address, owner, spenderAddr)
+	if err != nil {
+		return err
+	}
+	log.Printf("current allowance of %s: %s\n", spenderAddr.Hex(), formatAllowance(current, token))
+	if current.Sign() > 0 && value.Sign() > 0 && current.Cmp(value) != 0 {
+		// the spender may transfer the current allowance before the approve
+		// is mined and then the new one, some tokens (USDT) even reject it
+		log.Printf("WARNING: changing a nonzero allowance lets %s spend the current and the new allowance, revoke it first\n", spenderAddr.Hex())
+	}
+	return cli.sendTokenCall(ctx, owner, token, "approve", opts, yes, confirmations, spenderAddr, value)
+}
+
+// Revoke sets the allowance of spender of the token symbol of from to 0
+func (cli *CLI) Revoke(from, symbol, spender string, opts TxOptions, yes bool, confirmations uint64) error {
+	ctx := context.Background()
+	token, err := cli.lookupToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	owner, spenderAddr := common.HexToAddress(from), common.HexToAddress(spender)
+	current, err := tokenAllowance(ctx, client, token.Address, owner, spenderAddr)
+	if err != nil {
+		return err
+	}
+	if current.Sign() == 0 {
+		log.Printf("%s has no allowance of %s from %s, nothing to revoke\n", spenderAddr.Hex(), token.Symbol, owner.Hex())
+		return nil
+	}
+	log.Printf("revoke the allowance of %s: %s\n", spenderAddr.Hex(), formatAllowance(current, token))
+	return cli.sendTokenCall(ctx, owner, token, "approve", opts, yes, confirmations, spenderAddr, new(big.Int))
+}
+
+// Allowance prints the amount of the token symbol of owner that spender
+// can transfer, at most the balance of owner
+func (cli *CLI) Allowance(owner, spender, symbol string) error {
+	ctx := context.Background()
+	token, err := cli.lookupToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	ownerAddr, spenderAddr := common.HexToAddress(owner), common.HexToAddress(spender)
+	allowance, err := tokenAllowance(ctx, client, token.Address, ownerAddr, spenderAddr)
+	if err != nil {
+		return err
+	}
+	balance, err := tokenBalance(ctx, client, token.Address, ownerAddr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("allowance of %s from %s: %s\n", spenderAddr.Hex(), ownerAddr.Hex(), formatAllowance(allowance, token))
+	fmt.Printf("balance of %s: %s\n", ownerAddr.Hex(), token.Format(balance))
+	return nil
+}
+
+// TransferFrom transfers amount of the token symbol of owner to to with
+// the allowance given by owner to the account spender
+func (cli *CLI) TransferFrom(spender, owner, to, symbol, amount string, opts TxOptions, yes bool, confirmations uint64) error {
+	ctx := context.Background()
+	token, err := cli.lookupToken(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	value, err := token.Parse(amount)
+	if err != nil {
+		return err
+	}
+	if value.Sign() == 0 {
+		return fmt.Errorf("invalid value %s", amount)
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	spenderAddr, ownerAddr := common.HexToAddress(spender), common.HexToAddress(owner)
+	allowance, err := tokenAllowance(ctx, client, token.Address, ownerAddr, spenderAddr)
+	if err != nil {
+		return err
+	}
+	if allowance.Cmp(value) < 0 {
+		return fmt.Errorf("%s can transfer only %s of %s", spenderAddr.Hex(), formatAllowance(allowance, token), ownerAddr.Hex())
+	}
+	balance, err := tokenBalance(ctx, client, token.Address, ownerAddr)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(value) < 0 {
+		return fmt.Errorf("the balance of %s is only %s", ownerAddr.Hex(), token.Format(balance))
+	}
+	return cli.sendTokenCall(ctx, spenderAddr, token, "transferFrom", opts, yes, confirmations, ownerAddr, common.HexToAddress(to), value)
+}
+
+// GetAllowances finds the spenders approved by the accounts of the wallet
+// name in the Approval events of the registered tokens since fromBlock, and
+// returns the allowances they still have. Approvals of tokens missing from
+// the registry are not found.
+func (cli *CLI) GetAllowances(name string, fromBlock uint64) ([]Approval, error) {
+	addrs, err := cli.walletAccounts(name)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return nil, err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if fromBlock > head {
+		return nil, fmt.Errorf("block %d is after the latest block %d", fromBlock, head)
+	}
+
+	var approvals []Approval
+	for i := range tokens {
+		token, err := cli.tokenMeta(ctx, client, &tokens[i])
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %v", tokens[i].Key(), err)
+		}
+		filterer, err := abi.NewERC20Filterer(token.Address, client)
+		if err != nil {
+			return nil, err
+		}
+		// the last Approval of each owner and spender, in the order found
+		type pair struct{ owner, spender common.Address }
+		var pairs []pair
+		last := make(map[pair]*abi.ERC20Approval)
+		for start := fromBlock; start <= head; start += approvalLogBlocks {
+			end := start + approvalLogBlocks - 1
+			if end > head {
+				end = head
+			}
+			it, err := filterer.FilterApproval(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, addrs, nil)
+			if err != nil {
+				return nil, fmt.Errorf("Approval logs of %s: %v", token.Symbol, err)
+			}
+			for it.Next() {
+				p := pair{it.Event.Owner, it.Event.Spender}
+				if _, ok := last[p]; !ok {
+					pairs = append(pairs, p)
+				}
+				last[p] = it.Event
+			}
+			err = it.Error()
+			it.Close()
+			if err != nil {
+				return nil, fmt.Errorf("Approval logs of %s: %v", token.Symbol, err)
+			}
+		}
+		// the allowances decrease with transferFrom, only the current
+		// ones are outstanding
+		for _, p := range pairs {
+			allowance, err := tokenAllowance(ctx, client, token.Address, p.owner, p.spender)
+			if err != nil {
+				return nil, fmt.Errorf("allowance of %s from %s: %v", p.spender.Hex(), p.owner.Hex(), err)
+			}
+			if allowance.Sign() == 0 {
+				continue
+			}
+			units := "unlimited"
+			if allowance.Cmp(math.MaxBig256) != 0 {
+				units = formatAsset(allowance, token)
+			}
+			event := last[p]
+			approvals = append(approvals, Approval{
+				Token:     token.Symbol,
+				Address:   token.Address.Hex(),
+				Owner:     p.owner.Hex(),
+				Spender:   p.spender.Hex(),
+				Allowance: units,
+				Block:     event.Raw.BlockNumber,
+				Tx:        event.Raw.TxHash.Hex(),
+			})
+		}
+	}
+	return approvals, nil
+}
+
+// PrintAllowances prints the outstanding approvals as a table or as json
+func PrintAllowances(approvals []Approval, jsonOut bool) error {
+	if jsonOut {
+		if approvals == nil {
+			approvals = []Approval{}
+		}
+		data, err := json.MarshalIndent(approvals, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(approvals) == 0 {
+		fmt.Println("no outstanding approvals")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tOWNER\tSPENDER\tALLOWANCE\tAPPROVED AT")
+	for _, a := range approvals {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d %s\n", a.Token, a.Owner, a.Spender, a.Allowance, a.Block, a.Tx)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"wallet/keystorecode"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tempKeyFile matches the hidden files created by utils.WriteKeyFile before
+// they are renamed into place: ".<name>.tmp<random digits>".
+var tempKeyFile = regexp.MustCompile(`^\..+\.tmp[0-9]*$`)
+
+// KeyFileAudit is the audit result of a single keystore file
+type KeyFileAudit struct {
+	File        string   `json:"file"`
+	Mode        string   `json:"mode"`
+	Parsed      bool     `json:"parsed"`
+	Version     string   `json:"version,omitempty"`
+	KDF         string   `json:"kdf,omitempty"`
+	FileAddress string   `json:"fileAddress,omitempty"`
+	JSONAddress string   `json:"jsonAddress,omitempty"`
+	KeyAddress  string   `json:"keyAddress,omitempty"`
+	Decrypted   *bool    `json:"decrypted,omitempty"`
+	Issues      []string `json:"issues,omitempty"`
+}
+
+// AuditReport is the audit result of a wallet directory and database
+type AuditReport struct {
+	Wallet     string         `json:"wallet"`
+	Dir        string         `json:"dir"`
+	DirMode    string         `json:"dirMode"`
+	DB         string         `json:"db,omitempty"`
+	DBMode     string         `json:"dbMode,omitempty"`
+	Files      []KeyFileAudit `json:"files"`
+	Duplicates []string       `json:"duplicates,omitempty"`
+	TempFiles  []string       `json:"tempFiles,omitempty"`
+	Issues     []string       `json:"issues,omitempty"`
+	Problems   int            `json:"problems"`
+}
+
+// Audit checks every keystore file and database key of the wallet, when
+// decrypt is set the keys are decrypted with pass as well
+func (cli *CLI) Audit(name, pass string, decrypt bool) (*AuditReport, error) {
+	dir, db := cli.DataPath+"/"+name, cli.walletDB(name)
+	report := &AuditReport{Wallet: name, Dir: dir, Files: []KeyFileAudit{}}
+
+	dbInfo, dbErr := os.Stat(db)
+	var infos []os.FileInfo
+	dirInfo, err := os.Stat(dir)
+	if err == nil {
+		report.DirMode = dirInfo.Mode().Perm().String()
+		if looserThan(dirInfo.Mode(), 0700) {
+			report.Issues = append(report.Issues, fmt.Sprintf("wallet directory permissions %s are looser than 0700", report.DirMode))
+		}
+		if infos, err = ioutil.ReadDir(dir); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) || dbErr != nil {
+		// migrate -to db -remove may leave no key files
+		return nil, err
+	}
+
+	byAddr := make(map[common.Address][]accounts.Account)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if tempKeyFile.MatchString(info.Name()) {
+			report.TempFiles = append(report.TempFiles, info.Name())
+			continue
+		}
+		if strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), "~") {
+			continue
+		}
+
+		path := filepath.Join(dir, info.Name())
+		fa := auditKeyFile(path, info, pass, decrypt)
+		if fa.JSONAddress != "" {
+			addr := common.HexToAddress(fa.JSONAddress)
+			byAddr[addr] = append(byAddr[addr], accounts.Account{
+				Address: addr,
+				URL:     accounts.URL{Scheme: keystorecode.KeyStoreScheme, Path: path},
+			})
+		}
+		report.Files = append(report.Files, fa)
+	}
+
+	if dbErr == nil {
+		report.DB = db
+		report.DBMode = dbInfo.Mode().Perm().String()
+		if looserThan(dbInfo.Mode(), 0600) {
+			report.Issues = append(report.Issues, fmt.Sprintf("wallet database permissions %s are looser than 0600", report.DBMode))
+		}
+		keys, err := keystorecode.ReadDBKeys(db)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]common.Address, 0, len(keys))
+		for addr := range keys {
+			addrs = append(addrs, addr)
+		}
+		sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+		for _, addr := range addrs {
+			// the database stores the keys by address, which is
+			// checked like the address in a key file name
+			fa := KeyFileAudit{
+				File:        filepath.Base(db) + "/" + addr.Hex(),
+				Mode:        report.DBMode,
+				FileAddress: addr.Hex(),
+			}
+			auditKeyJSON(&fa, keys[addr], pass, decrypt)
+			report.Files = append(report.Files, fa)
+		}
+	}
+
+	// the same address stored in several files makes the keystore
+	// return an AmbiguousAddrError on unlock
+	for addr, matches := range byAddr {
+		if len(matches) > 1 {
+			err := &keystorecode.AmbiguousAddrError{Addr: addr, Matches: matches}
+			report.Duplicates = append(report.Duplicates, fmt.Sprintf("%s: %s", addr.Hex(), err.Error()))
+		}
+	}
+	sort.Strings(report.Duplicates)
+
+	report.Problems = len(report.Issues) + len(report.Duplicates) + len(report.TempFiles)
+	for _, fa := range report.Files {
+		report.Problems += len(fa.Issues)
+	}
+	return report, nil
+}
+
+func auditKeyFile(path string, info os.FileInfo, pass string, decrypt bool) KeyFileAudit {
+	fa := KeyFileAudit{
+		File:        info.Name(),
+		Mode:        info.Mode().Perm().String(),
+		FileAddress: addressFromFileName(info.Name()),
+	}
+	if looserThan(info.Mode(), 0600) {
+		fa.Issues = append(fa.Issues, fmt.Sprintf("file permissions %s are looser than 0600", fa.Mode))
+	}
+
+	keyjson, err := ioutil.ReadFile(path)
+	if err != nil {
+		fa.Issues = append(fa.Issues, "failed to read file: "+err.Error())
+		return fa
+	}
+	if fa.FileAddress == "" {
+		fa.Issues = append(fa.Issues, "no address in file name")
+	}
+	auditKeyJSON(&fa, keyjson, pass, decrypt)
+	return fa
+}
+
+// auditKeyJSON checks the content of a key, from a key file or the database
+func auditKeyJSON(fa *KeyFileAudit, keyjson []byte, pass string, decrypt bool) {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(keyjson, &m); err != nil {
+		fa.Issues = append(fa.Issues, "invalid json: "+err.Error())
+		return
+	}
+	fa.Parsed = true
+
+	// version 3 stores an integer and "crypto", version 1 a string and "Crypto"
+	switch v := m["version"].(type) {
+	case float64:
+		fa.Version = fmt.Sprintf("%d", int(v))
+	case string:
+		fa.Version = v
+	default:
+		fa.Issues = append(fa.Issues, "missing version")
+	}
+	cryptoSection, ok := m["crypto"].(map[string]interface{})
+	if !ok {
+		cryptoSection, _ = m["Crypto"].(map[string]interface{})
+	}
+	if kdf, ok := cryptoSection["kdf"].(string); ok {
+		fa.KDF = kdf
+	} else {
+		fa.Issues = append(fa.Issues, "missing kdf")
+	}
+
+	jsonAddr, _ := m["address"].(string)
+	if !common.IsHexAddress(jsonAddr) {
+		fa.Issues = append(fa.Issues, "missing or invalid address")
+	} else {
+		fa.JSONAddress = common.HexToAddress(jsonAddr).Hex()
+		if fa.FileAddress != "" && !strings.EqualFold(fa.FileAddress, fa.JSONAddress) {
+			fa.Issues = append(fa.Issues, fmt.Sprintf("file name address %s does not match json address %s", fa.FileAddress, fa.JSONAddress))
+		}
+	}
+
+	if !decrypt {
+		return
+	}
+	key, err := keystorecode.DecryptKey(keyjson, pass)
+	ok = err == nil
+	fa.Decrypted = &ok
+	if err != nil {
+		fa.Issues = append(fa.Issues, "failed to decrypt: "+err.Error())
+		return
+	}
+	fa.KeyAddress = key.Address.Hex()
+	if fa.JSONAddress != "" && fa.KeyAddress != fa.JSONAddress {
+		fa.Issues = append(fa.Issues, fmt.Sprintf("decrypted key address %s does not match json address %s", fa.KeyAddress, fa.JSONAddress))
+	}
+	if fa.FileAddress != "" && !strings.EqualFold(fa.FileAddress, fa.KeyAddress) {
+		fa.Issues = append(fa.Issues, fmt.Sprintf("decrypted key address %s does not match file name address %s", fa.KeyAddress, fa.FileAddress))
+	}
+}
+
+// addressFromFileName gets the address of UTC--<created_at>--<address>,
+// both with and without 0x prefix
+func addressFromFileName(name string) string {
+	i := strings.LastIndex(name, "--")
+	if i < 0 {
+		return ""
+	}
+	addr := name[i+2:]
+	if !common.IsHexAddress(addr) {
+		return ""
+	}
+	return common.HexToAddress(addr).Hex()
+}
+
+// looserThan reports whether mode grants any permission that perm does not,
+// windows has no unix permissions so it is always false there
+func looserThan(mode os.FileMode, perm os.FileMode) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	return mode.Perm()&^perm != 0
+}
+
+// PrintAudit prints the report as text or json
+func PrintAudit(report *AuditReport, asJSON bool) {
+	if asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("wallet: %s dir: %s mode: %s\n", report.Wallet, report.Dir, report.DirMode)
+	if report.DB != "" {
+		fmt.Printf("database: %s mode: %s\n", report.DB, report.DBMode)
+	}
+	for _, issue := range report.Issues {
+		fmt.Println("  [WARN]", issue)
+	}
+	for _, fa := range report.Files {
+		status := "OK"
+		if len(fa.Issues) > 0 {
+			status = "FAIL"
+		}
+		decrypted := "-"
+		if fa.Decrypted != nil {
+			decrypted = fmt.Sprintf("%v", *fa.Decrypted)
+		}
+		fmt.Printf("[%s] %s version: %s kdf: %s address: %s mode: %s decrypted: %s\n",
+			status, fa.File, fa.Version, fa.KDF, fa.JSONAddress, fa.Mode, decrypted)
+		for _, issue := range fa.Issues {
+			fmt.Println("  [WARN]", issue)
+		}
+	}
+	for _, dup := range report.Duplicates {
+		fmt.Println("[DUPLICATE]", dup)
+	}
+	for _, tmp := range report.TempFiles {
+		fmt.Println("[TEMPFILE]", tmp)
+	}
+	fmt.Printf("%d files, %d problems\n", len(report.Files), report.Problems)
+}
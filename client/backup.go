@@ -0,0 +1,319 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wallet/keystorecode"
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// backupVersion is the version of the backup archive format
+const backupVersion = 1
+
+// BackupArchive is the on-disk backup file, everything but the header is
+// encrypted and authenticated in Crypto
+type BackupArchive struct {
+	Version int                     `json:"version"`
+	Wallet  string                  `json:"wallet"`
+	Created string                  `json:"created"`
+	Crypto  keystorecode.CryptoJSON `json:"crypto"`
+}
+
+// backupPayload is the decrypted content of a backup archive, the header is
+// repeated so that it is covered by the mac too
+type backupPayload struct {
+	Version  int             `json:"version"`
+	Wallet   string          `json:"wallet"`
+	Created  string          `json:"created"`
+	Accounts []string        `json:"accounts"`
+	Files    []backupFile    `json:"files"`
+	Tokens   json.RawMessage `json:"tokens,omitempty"`
+}
+
+type backupFile struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Data    []byte `json:"data"`
+	// DB is set for the keys of the wallet database, they are restored
+	// into the database and not as key files
+	DB bool `json:"db,omitempty"`
+}
+
+// Backup writes the wallet's keystore files, database keys, metadata and
+// token registry into a single encrypted archive
+func (cli *CLI) Backup(name, out, pass string) error {
+	dir, db := cli.DataPath+"/"+name, cli.walletDB(name)
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		// migrate -to db -remove may leave no key files
+		if _, dbErr := os.Stat(db); dbErr == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	payload := backupPayload{
+		Version:  backupVersion,
+		Wallet:   name,
+		Created:  time.Now().UTC().Format(time.RFC3339),
+		Accounts: []string{},
+		Files:    []backupFile{},
+	}
+	for _, info := range infos {
+		// skip directories and the temp files of interrupted writes
+		if !info.Mode().IsRegular() || tempKeyFile.MatchString(info.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return err
+		}
+		file := backupFile{Name: info.Name(), Data: data}
+		if addr, ok := keyFileAddress(data); ok {
+			file.Address = addr.Hex()
+			payload.Accounts = append(payload.Accounts, file.Address)
+		}
+		payload.Files = append(payload.Files, file)
+	}
+	if _, err := os.Stat(db); err == nil {
+		keys, err := keystorecode.ReadDBKeys(db)
+		if err != nil {
+			return err
+		}
+		addrs := make([]common.Address, 0, len(keys))
+		for addr := range keys {
+			addrs = append(addrs, addr)
+		}
+		sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+		seen := make(map[string]bool)
+		for _, a := range payload.Accounts {
+			seen[a] = true
+		}
+		for _, addr := range addrs {
+			payload.Files = append(payload.Files, backupFile{
+				Name:    hex.EncodeToString(addr[:]),
+				Address: addr.Hex(),
+				Data:    keys[addr],
+				DB:      true,
+			})
+			if !seen[addr.Hex()] {
+				payload.Accounts = append(payload.Accounts, addr.Hex())
+			}
+		}
+	}
+	if len(payload.Accounts) == 0 {
+		return fmt.Errorf("no keystore file found in %s or %s", dir, db)
+	}
+
+	if tokens, err := ioutil.ReadFile(cli.TokensFile); err == nil {
+		payload.Tokens = tokens
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	plain, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	cryptoStruct, err := keystorecode.EncryptDataV3(plain, []byte(pass), keystorecode.StandardScryptN, keystorecode.StandardScryptP)
+	if err != nil {
+		return err
+	}
+	archive := BackupArchive{
+		Version: payload.Version,
+		Wallet:  payload.Wallet,
+		Created: payload.Created,
+		Crypto:  cryptoStruct,
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteKeyFile(out, data); err != nil {
+		return err
+	}
+	log.Printf("backup %d accounts, %d files of wallet %s to %s\n", len(payload.Accounts), len(payload.Files), name, out)
+	return nil
+}
+
+// Restore verifies and decrypts a backup archive and writes its content into
+// the wallet directory, name overrides the wallet name stored in the archive
+func (cli *CLI) Restore(in, name, pass string, force bool) error {
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	archive := new(BackupArchive)
+	if err := json.Unmarshal(data, archive); err != nil {
+		return fmt.Errorf("invalid backup archive: %v", err)
+	}
+	if archive.Version != backupVersion {
+		return fmt.Errorf("backup version not supported: %d", archive.Version)
+	}
+	// the mac is checked before anything is written
+	plain, err := keystorecode.DecryptDataV3(archive.Crypto, pass)
+	if err != nil {
+		return fmt.Errorf("failed to verify backup archive: %v", err)
+	}
+	payload := new(backupPayload)
+	if err := json.Unmarshal(plain, payload); err != nil {
+		return fmt.Errorf("invalid backup payload: %v", err)
+	}
+	if payload.Version != archive.Version || payload.Wallet != archive.Wallet || payload.Created != archive.Created {
+		return fmt.Errorf("backup header does not match its encrypted content")
+	}
+	for _, file := range payload.Files {
+		if file.Name != filepath.Base(file.Name) || strings.HasPrefix(file.Name, ".") {
+			return fmt.Errorf("invalid file name in backup: %s", file.Name)
+		}
+		if file.DB && file.Address == "" {
+			return fmt.Errorf("database key %s has no address", file.Name)
+		}
+		if file.Address != "" {
+			if addr, ok := keyFileAddress(file.Data); !ok || addr.Hex() != file.Address {
+				return fmt.Errorf("keystore file %s does not match address %s", file.Name, file.Address)
+			}
+		}
+	}
+
+	if name == "" {
+		name = payload.Wallet
+	}
+	dir, db := cli.DataPath+"/"+name, cli.walletDB(name)
+
+	// collect the accounts which already exist in the wallet directory
+	existing := make(map[string][]string)
+	if infos, err := ioutil.ReadDir(dir); err == nil {
+		for _, info := range infos {
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			content, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+			if err != nil {
+				return err
+			}
+			if addr, ok := keyFileAddress(content); ok {
+				existing[addr.Hex()] = append(existing[addr.Hex()], info.Name())
+			}
+		}
+	}
+	var dbKeys map[common.Address][]byte
+	if _, err := os.Stat(db); err == nil {
+		if dbKeys, err = keystorecode.ReadDBKeys(db); err != nil {
+			return err
+		}
+	}
+	var conflicts []string
+	for _, file := range payload.Files {
+		if file.DB {
+			if _, ok := dbKeys[common.HexToAddress(file.Address)]; ok {
+				conflicts = append(conflicts, file.Address)
+			}
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, file.Name)); err == nil {
+			conflicts = append(conflicts, file.Name)
+		} else if file.Address != "" && len(existing[file.Address]) > 0 {
+			conflicts = append(conflicts, file.Address)
+		}
+	}
+	if len(conflicts) > 0 && !force {
+		return fmt.Errorf("wallet %s already has %s, use -force to overwrite", name, strings.Join(conflicts, ", "))
+	}
+
+	restored := make(map[string]bool)
+	for _, file := range payload.Files {
+		restored[file.Name] = true
+	}
+	restoredKeys := make(map[common.Address][]byte)
+	for _, file := range payload.Files {
+		if file.DB {
+			restoredKeys[common.HexToAddress(file.Address)] = file.Data
+			continue
+		}
+		// drop the other key files of the same account so the
+		// keystore does not end up with ambiguous addresses
+		for _, old := range existing[file.Address] {
+			if restored[old] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, old)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := utils.WriteKeyFile(filepath.Join(dir, file.Name), file.Data); err != nil {
+			return err
+		}
+	}
+	if len(restoredKeys) > 0 {
+		if err := keystorecode.WriteDBKeys(db, restoredKeys); err != nil {
+			return err
+		}
+	}
+	if len(payload.Tokens) > 0 {
+		if err := cli.mergeTokens(payload.Tokens, force); err != nil {
+			return err
+		}
+	}
+	log.Printf("restore %d accounts, %d files of wallet %s to %s\n", len(payload.Accounts), len(payload.Files), payload.Wallet, dir)
+	return nil
+}
+
+// mergeTokens adds the tokens of a backup to the token registry, tokens of
+// an already registered address are only replaced when force is set
+func (cli *CLI) mergeTokens(data []byte, force bool) error {
+	restored := []TokenConfig{}
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return fmt.Errorf("invalid token registry in backup: %v", err)
+	}
+	return cli.updateTokens(func(tokens []TokenConfig) ([]TokenConfig, error) {
+	restore:
+		for _, token := range restored {
+			for i := range tokens {
+				if strings.EqualFold(tokens[i].Addr, token.Addr) {
+					if force {
+						tokens[i] = token
+					}
+					continue restore
+				}
+			}
+			for i := range tokens {
+				if tokens[i].Key() == token.Key() {
+					log.Printf("token %s %s of the backup not restored: %s is the name of %s, add it with addtoken -alias NAME\n", token.Key(), token.Addr, token.Key(), tokens[i].Addr)
+					continue restore
+				}
+			}
+			tokens = append(tokens, token)
+		}
+		return tokens, nil
+	})
+}
+
+// keyFileAddress returns the address of a keystore json file
+func keyFileAddress(keyjson []byte) (common.Address, bool) {
+	var key struct {
+		Address string `json:"address"`
+		Crypto  json.RawMessage
+	}
+	if err := json.Unmarshal(keyjson, &key); err != nil || len(key.Crypto) == 0 {
+		return common.Address{}, false
+	}
+	if !common.IsHexAddress(key.Address) {
+		return common.Address{}, false
+	}
+	return common.HexToAddress(key.Address), true
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+余额批量查询: portfolio 等命令一次查询很多账户的 ether 和 token 余额, 逐个查询时每个余额一次请求.
+网络配置了 Multicall3 合约("multicall": "0xcA11bde05977b3631167028862bE2a173976CA11")时,
+每 maxBatchSize 个余额合并为一个 eth_call; 否则使用 JSON-RPC batch, 每 maxBatchSize 个请求一次发送.
+multicall 失败(如该区块时合约还未部署)时改用 batch, 节点不支持 batch 时再逐个查询.
+*/
+
+// maxBatchSize is the number of balances read by one multicall or one
+// JSON-RPC batch, nodes limit the size of batches
+const maxBatchSize = 100
+
+// multicall3ABI is the part of Multicall3 used to read balances
+const multicall3ABI = `[
+{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},
+{"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+
+var multicallABI, _ = ethabi.JSON(strings.NewReader(multicall3ABI))
+
+// multicallCall and multicallResult are the tuples of aggregate3
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// blockArg is the block parameter of a read at block, the latest if nil
+func blockArg(block *big.Int) string {
+	if block == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(block)
+}
+
+// decodeBalance decodes the result of balanceOf. Calling an address
+// without code returns nothing, it is reported as bind.ErrNoCode like the
+// bindings do.
+func decodeBalance(out []byte) (*big.Int, error) {
+	if len(out) == 0 {
+		return nil, bind.ErrNoCode
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("balanceOf returned %s", hexutil.Encode(out))
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+// fetchBalances fills the balances of queries at block with the fewest
+// round-trips: through the Multicall3 contract multicall if not nil, then
+// with JSON-RPC batches, and with workers concurrent single calls if both
+// fail. It returns the number of requests sent to the node.
+func fetchBalances(ctx context.Context, rc *rpc.Client, multicall *common.Address, queries []*balanceQuery, block *big.Int, workers int) int {
+	if len(queries) == 0 {
+		return 0
+	}
+	requests := 0
+	if multicall != nil {
+		n, err := multicallBalances(ctx, rc, *multicall, queries, block)
+		requests += n
+		if err == nil {
+			return requests
+		}
+		log.Println("multicall failed, using batch requests: ", err)
+	}
+	n, err := batchBalances(ctx, rc, queries, block)
+	requests += n
+	if err == nil {
+		return requests
+	}
+	log.Println("batch request failed, querying the balances one by one: ", err)
+	queryBalances(ctx, ethclient.NewClient(rc), queries, block, workers)
+	return requests + len(queries)
+}
+
+// fetchBalances fills the balances of queries at block with the Multicall3
+// contract of the network or JSON-RPC batches, see fetchBalances
+func (cli *CLI) fetchBalances(ctx context.Context, queries []*balanceQuery, block *big.Int, workers int) error {
+	rc, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	var multicall *common.Address
+	if cli.Network.Multicall != "" {
+		addr := common.HexToAddress(cli.Network.Multicall)
+		multicall = &addr
+	}
+	requests := fetchBalances(ctx, rc, multicall, queries, block, workers)
+	log.Printf("%d balances read with %d requests\n", len(queries), requests)
+	return nil
+}
+
+// batchBalances reads the balances of queries with eth_getBalance and
+// eth_call requests sent in JSON-RPC batches of maxBatchSize. The error of
+// a request is the error of its query, an error of a whole batch is
+// returned; the queries of the batches already sent are kept. It returns
+// the number of batches sent.
+func batchBalances(ctx context.Context, rc *rpc.Client, queries []*balanceQuery, block *big.Int) (int, error) {
+	batches := 0
+	for start := 0; start < len(queries); start += maxBatchSize {
+		chunk := queries[start:minInt(start+maxBatchSize, len(queries))]
+		elems := make([]rpc.BatchElem, len(chunk))
+		for i, q := range chunk {
+			if q.Token == nil {
+				elems[i] = rpc.BatchElem{Method: "eth_getBalance", Args: []interface{}{q.Account, blockArg(block)}, Result: new(hexutil.Big)}
+				continue
+			}
+			data, err := erc20ABI.Pack("balanceOf", q.Account)
+			if err != nil {
+				return batches, err
+			}
+			call := map[string]interface{}{"to": q.Token, "data": hexutil.Bytes(data)}
+			elems[i] = rpc.BatchElem{Method: "eth_call", Args: []interface{}{call, blockArg(block)}, Result: new(hexutil.Bytes)}
+		}
+		batches++
+		if err := rc.BatchCallContext(ctx, elems); err != nil {
+			return batches, err
+		}
+		for i, q := range chunk {
+			if q.Err = elems[i].Error; q.Err != nil {
+				continue
+			}
+			switch result := elems[i].Result.(type) {
+			case *hexutil.Big:
+				q.Balance = result.ToInt()
+			case *hexutil.Bytes:
+				q.Balance, q.Err = decodeBalance(*result)
+			}
+		}
+	}
+	return batches, nil
+}
+
+// multicallBalances reads the balances of queries with aggregate3 calls of
+// maxBatchSize calls to the Multicall3 contract multicall: balanceOf of the
+// tokens and getEthBalance of multicall for ether. A call that fails fails
+// its query only. It returns the number of eth_call sent.
+func multicallBalances(ctx context.Context, rc *rpc.Client, multicall common.Address, queries []*balanceQuery, block *big.Int) (int, error) {
+	requests := 0
+	for start := 0; start < len(queries); start += maxBatchSize {
+		chunk := queries[start:minInt(start+maxBatchSize, len(queries))]
+		calls := make([]multicallCall, len(chunk))
+		for i, q := range chunk {
+			var err error
+			if q.Token == nil {
+				calls[i].Target = multicall
+				calls[i].CallData, err = multicallABI.Pack("getEthBalance", q.Account)
+			} else {
+				calls[i].Target = *q.Token
+				calls[i].CallData, err = erc20ABI.Pack("balanceOf", q.Account)
+			}
+			if err != nil {
+				return requests, err
+			}
+			calls[i].AllowFailure = true
+		}
+		data, err := multicallABI.Pack("aggregate3", calls)
+		if err != nil {
+			return requests, err
+		}
+		var out hexutil.Bytes
+		call := map[string]interface{}{"to": multicall, "data": hexutil.Bytes(data)}
+		requests++
+		if err := rc.CallContext(ctx, &out, "eth_call", call, blockArg(block)); err != nil {
+			return requests, err
+		}
+		if len(out) == 0 {
+			return requests, fmt.Errorf("no Multicall3 contract at %s at the block", multicall.Hex())
+		}
+		values, err := multicallABI.Unpack("aggregate3", out)
+		if err != nil {
+			return requests, err
+		}
+		results := *ethabi.ConvertType(values[0], new([]multicallResult)).(*[]multicallResult)
+		if len(results) != len(chunk) {
+			return requests, fmt.Errorf("multicall returned %d results for %d calls", len(results), len(chunk))
+		}
+		for i, q := range chunk {
+			if !results[i].Success {
+				q.Err = fmt.Errorf("the call %s", revertReason(results[i].ReturnData))
+				continue
+			}
+			q.Balance, q.Err = decodeBalance(results[i].ReturnData)
+		}
+	}
+	return requests, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeEth answers eth_getBalance and eth_call of balanceOf and of a
+// Multicall3 contract from fixed balances
+type fakeEth struct {
+	multicall common.Address
+	ether     map[common.Address]*big.Int
+	tokens    map[common.Address]map[common.Address]*big.Int
+}
+
+type fakeCallArgs struct {
+	To   common.Address `json:"to"`
+	Data hexutil.Bytes  `json:"data"`
+}
+
+func (f *fakeEth) GetBalance(account common.Address, block string) *hexutil.Big {
+	return (*hexutil.Big)(f.balance(f.ether, account))
+}
+
+func (f *fakeEth) Call(args fakeCallArgs, block string) (hexutil.Bytes, error) {
+	return f.call(args.To, args.Data)
+}
+
+func (f *fakeEth) balance(balances map[common.Address]*big.Int, account common.Address) *big.Int {
+	if b, ok := balances[account]; ok {
+		return b
+	}
+	return new(big.Int)
+}
+
+func (f *fakeEth) call(to common.Address, data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, nil
+	}
+	method, args := data[:4], data[4:]
+	if balances, ok := f.tokens[to]; ok && bytes.Equal(method, erc20ABI.Methods["balanceOf"].ID) {
+		values, err := erc20ABI.Methods["balanceOf"].Inputs.Unpack(args)
+		if err != nil {
+			return nil, err
+		}
+		return erc20ABI.Methods["balanceOf"].Outputs.Pack(f.balance(balances, values[0].(common.Address)))
+	}
+	if to != f.multicall {
+		// an address without code
+		return nil, nil
+	}
+	switch {
+	case bytes.Equal(method, multicallABI.Methods["getEthBalance"].ID):
+		values, err := multicallABI.Methods["getEthBalance"].Inputs.Unpack(args)
+		if err != nil {
+			return nil, err
+		}
+		return multicallABI.Methods["getEthBalance"].Outputs.Pack(f.balance(f.ether, values[0].(common.Address)))
+	case bytes.Equal(method, multicallABI.Methods["aggregate3"].ID):
+		values, err := multicallABI.Methods["aggregate3"].Inputs.Unpack(args)
+		if err != nil {
+			return nil, err
+		}
+		calls := *ethabi.ConvertType(values[0], new([]multicallCall)).(*[]multicallCall)
+		results := make([]multicallResult, len(calls))
+		for i, c := range calls {
+			out, err := f.call(c.Target, c.CallData)
+			results[i] = multicallResult{Success: err == nil, ReturnData: out}
+		}
+		return multicallABI.Methods["aggregate3"].Outputs.Pack(results)
+	}
+	return nil, fmt.Errorf("unknown method %x", method)
+}
+
+// newFakeNode serves a fakeEth with accounts accounts holding ether and
+// tokens tokens over http, and counts the http requests
+func newFakeNode(t testing.TB, accounts, tokens int) (*fakeEth, *httptest.Server, *int64) {
+	f := &fakeEth{
+		multicall: common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11"),
+		ether:     make(map[common.Address]*big.Int),
+		tokens:    make(map[common.Address]map[common.Address]*big.Int),
+	}
+	for i := 0; i < accounts; i++ {
+		f.ether[common.BigToAddress(big.NewInt(int64(1000+i)))] = big.NewInt(int64(1e9 + i))
+	}
+	for j := 0; j < tokens; j++ {
+		balances := make(map[common.Address]*big.Int)
+		for i := 0; i < accounts; i++ {
+			balances[common.BigToAddress(big.NewInt(int64(1000+i)))] = big.NewInt(int64(100*j + i))
+		}
+		f.tokens[common.BigToAddress(big.NewInt(int64(2000+j)))] = balances
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", f); err != nil {
+		t.Fatal(err)
+	}
+	requests := new(int64)
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		server.ServeHTTP(w, r)
+	}))
+	return f, node, requests
+}
+
+// portfolioQueries are the ether and token balances of every account of f
+func portfolioQueries(accounts, tokens int) []*balanceQuery {
+	var queries []*balanceQuery
+	for i := 0; i < accounts; i++ {
+		account := common.BigToAddress(big.NewInt(int64(1000 + i)))
+		queries = append(queries, &balanceQuery{Account: account})
+		for j := 0; j < tokens; j++ {
+			token := common.BigToAddress(big.NewInt(int64(2000 + j)))
+			queries = append(queries, &balanceQuery{Account: account, Token: &token})
+		}
+	}
+	return queries
+}
+
+func checkQueries(t *testing.T, f *fakeEth, queries []*balanceQuery) {
+	for _, q := range queries {
+		want := f.balance(f.ether, q.Account)
+		if q.Token != nil {
+			want = f.balance(f.tokens[*q.Token], q.Account)
+		}
+		if q.Err != nil || q.Balance == nil || q.Balance.Cmp(want) != 0 {
+			t.Fatalf("balance of %s token %v: got %v, %v want %v", q.Account.Hex(), q.Token, q.Balance, q.Err, want)
+		}
+	}
+}
+
+func TestFetchBalances(t *testing.T) {
+	f, node, requests := newFakeNode(t, 30, 4)
+	defer node.Close()
+	rc, err := rpc.Dial(node.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	ctx := context.Background()
+	noCode := common.HexToAddress("0xdead")
+
+	tests := []struct {
+		name      string
+		multicall *common.Address
+		requests  int64
+	}{
+		{"batch", nil, 2},
+		{"multicall", &f.multicall, 2},
+		{"multicall without code falls back to batch", &noCode, 3},
+	}
+	for _, test := range tests {
+		atomic.StoreInt64(requests, 0)
+		queries := portfolioQueries(30, 4)
+		n := fetchBalances(ctx, rc, test.multicall, queries, big.NewInt(1), 4)
+		checkQueries(t, f, queries)
+		if got := atomic.LoadInt64(requests); got != test.requests || int64(n) != got {
+			t.Errorf("%s: %d requests, counted %d, want %d", test.name, got, n, test.requests)
+		}
+	}
+
+	// a token without code answers nothing
+	token := common.HexToAddress("0xbeef")
+	for _, multicall := range []*common.Address{nil, &f.multicall} {
+		q := &balanceQuery{Account: common.BigToAddress(big.NewInt(1000)), Token: &token}
+		fetchBalances(ctx, rc, multicall, []*balanceQuery{q}, nil, 1)
+		if q.Err == nil {
+			t.Errorf("multicall %v: no error for a token without code", multicall)
+		}
+	}
+}
+
+// The benchmarks read the 120 balances of a portfolio of 20 accounts and 5
+// tokens and report the requests sent to the node per portfolio.
+
+func benchmarkBalances(b *testing.B, fetch func(ctx context.Context, rc *rpc.Client, f *fakeEth, queries []*balanceQuery)) {
+	f, node, requests := newFakeNode(b, 20, 5)
+	defer node.Close()
+	rc, err := rpc.Dial(node.URL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rc.Close()
+	ctx := context.Background()
+	b.ResetTimer()
+	atomic.StoreInt64(requests, 0)
+	for i := 0; i < b.N; i++ {
+		fetch(ctx, rc, f, portfolioQueries(20, 5))
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(requests))/float64(b.N), "requests/op")
+}
+
+func BenchmarkBalancesSingleCalls(b *testing.B) {
+	benchmarkBalances(b, func(ctx context.Context, rc *rpc.Client, f *fakeEth, queries []*balanceQuery) {
+		queryBalances(ctx, ethclient.NewClient(rc), queries, nil, defaultPortfolioWorkers)
+	})
+}
+
+func BenchmarkBalancesBatch(b *testing.B) {
+	benchmarkBalances(b, func(ctx context.Context, rc *rpc.Client, f *fakeEth, queries []*balanceQuery) {
+		fetchBalances(ctx, rc, nil, queries, nil, defaultPortfolioWorkers)
+	})
+}
+
+func BenchmarkBalancesMulticall(b *testing.B) {
+	benchmarkBalances(b, func(ctx context.Context, rc *rpc.Client, f *fakeEth, queries []*balanceQuery) {
+		fetchBalances(ctx, rc, &f.multicall, queries, nil, defaultPortfolioWorkers)
+	})
+}
@@ -0,0 +1,568 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"wallet/hdkeystore"
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// statuses of a payout in the results file
+const (
+	PayoutMined    = "mined"    // paid
+	PayoutSigned   = "signed"   // signed, the broadcast may have failed: sent again by the next run
+	PayoutSent     = "sent"     // broadcast, not mined yet
+	PayoutReverted = "reverted" // mined but failed, paid again on the next run
+	PayoutError    = "error"    // not sent, paid again on the next run
+)
+
+// errPayoutCancelled is the error of a payout replaced by a cancel
+const errPayoutCancelled = "payout cancelled"
+
+// Payout is a row of the payouts file and its result
+type Payout struct {
+	Line    int
+	Address common.Address
+	Amount  *big.Int // wei, or token units
+	Status  string
+	Nonce   uint64
+	Hash    common.Hash
+	Error   string
+	Raw     []byte // signed transaction, to broadcast it again instead of signing another one
+}
+
+var payoutHeader = []string{"line", "address", "amount", "token", "status", "nonce", "hash", "error", "raw"}
+
+// parseAddress parses a hex address, checking the EIP-55 checksum of mixed case ones
+func parseAddress(s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address %q", s)
+	}
+	addr := common.HexToAddress(s)
+	hex := s
+	if strings.HasPrefix(hex, "0x") || strings.HasPrefix(hex, "0X") {
+		hex = hex[2:]
+	}
+	if hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) && hex != addr.Hex()[2:] {
+		return common.Address{}, fmt.Errorf("address %q has a wrong EIP-55 checksum, want %s", s, addr.Hex())
+	}
+	return addr, nil
+}
+
+// ReadPayouts reads the address,amount rows of a payouts csv file. Ether
+// amounts have units as in transfer, the amounts of a token are token units
+// with up to decimals decimals, e.g. 12.5. All rows are checked, the errors
+// of every invalid row are returned together.
+func ReadPayouts(file string, token bool, decimals uint8) ([]*Payout, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	var payouts []*Payout
+	var errs []string
+	seen := make(map[common.Address]int)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if len(payouts) == 0 && len(errs) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue // header
+		}
+		if len(record) < 2 {
+			errs = append(errs, fmt.Sprintf("line %d: want address,amount", line))
+			continue
+		}
+		addr, err := parseAddress(strings.TrimSpace(record[0]))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if addr == (common.Address{}) {
+			// a payout to the zero address burns it
+			errs = append(errs, fmt.Sprintf("line %d: %s is the zero address", line, record[0]))
+			continue
+		}
+		var amount *big.Int
+		if token {
+			amount, err = utils.ParseUnits(strings.TrimSpace(record[1]), int(decimals))
+		} else {
+			amount, err = utils.ParseAmount(record[1])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if amount.Sign() == 0 {
+			errs = append(errs, fmt.Sprintf("line %d: amount is zero", line))
+			continue
+		}
+		if first, ok := seen[addr]; ok {
+			errs = append(errs, fmt.Sprintf("line %d: %s is already paid on line %d", line, addr.Hex(), first))
+			continue
+		}
+		seen[addr] = line
+		payouts = append(payouts, &Payout{Line: line, Address: addr, Amount: amount})
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid payouts file %s:\n%s", file, strings.Join(errs, "\n"))
+	}
+	if len(payouts) == 0 {
+		return nil, fmt.Errorf("no payouts in %s", file)
+	}
+	return payouts, nil
+}
+
+// readPayoutResults reads the results file of a previous run, nil if there is none
+func readPayoutResults(file, token string) (map[common.Address]*Payout, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid results file %s: %v", file, err)
+	}
+	results := make(map[common.Address]*Payout)
+	for i, record := range records {
+		// results of older versions have no raw column
+		if i == 0 || len(record) < len(payoutHeader)-1 {
+			continue
+		}
+		if record[3] != token {
+			return nil, fmt.Errorf("results file %s is a payout of %s, not %s", file, record[3], token)
+		}
+		p := &Payout{Address: common.HexToAddress(record[1]), Status: record[4], Hash: common.HexToHash(record[6]), Error: record[7]}
+		p.Line, _ = strconv.Atoi(record[0])
+		p.Amount, _ = new(big.Int).SetString(record[2], 10)
+		p.Nonce, _ = strconv.ParseUint(record[5], 10, 64)
+		if len(record) == len(payoutHeader) && record[8] != "" {
+			if p.Raw, err = hexutil.Decode(record[8]); err != nil {
+				return nil, fmt.Errorf("invalid results file %s: line %s: %v", file, record[0], err)
+			}
+		}
+		results[p.Address] = p
+	}
+	return results, nil
+}
+
+// writePayoutResults replaces the results file with the payouts
+func writePayoutResults(file, token string, payouts []*Payout) error {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(payoutHeader)
+	for _, p := range payouts {
+		hash, nonce, raw := "", "", ""
+		if p.Hash != (common.Hash{}) {
+			hash, nonce = p.Hash.Hex(), strconv.FormatUint(p.Nonce, 10)
+		}
+		if len(p.Raw) > 0 {
+			raw = hexutil.Encode(p.Raw)
+		}
+		w.Write([]string{strconv.Itoa(p.Line), p.Address.Hex(), p.Amount.String(), token, p.Status, nonce, hash, p.Error, raw})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return utils.WriteKeyFile(file, []byte(b.String()))
+}
+
+// payoutMsg is the transfer paying p from from, in ether or in the token
+func payoutMsg(from common.Address, p *Payout, token *common.Address) (ethereum.CallMsg, error) {
+	if token == nil {
+		return ethereum.CallMsg{From: from, To: &p.Address, Value: p.Amount}, nil
+	}
+	data, err := erc20ABI.Pack("transfer", p.Address, p.Amount)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	return ethereum.CallMsg{From: from, To: token, Value: new(big.Int), Data: data}, nil
+}
+
+// resumePayout updates p from its transaction of a previous run. A payout
+// is paid again only when its transaction reverted, was cancelled or its
+// nonce was used by another transaction. A dropped transaction, or a signed
+// one whose broadcast failed, is broadcast again while its nonce is still
+// free instead, it is never signed again so that it can't be paid twice.
+func (cli *CLI) resumePayout(ctx context.Context, client *ethclient.Client, nm *NonceManager, msg ethereum.CallMsg, p *Payout) error {
+	if p.Hash == (common.Hash{}) {
+		p.Status = ""
+		return nil
+	}
+	// follow speedups and cancels of the payout
+	raw := p.Raw
+	cancelled := false
+	if _, rec, err := nm.Find(p.Hash); err == nil && len(rec.Raw) > 0 {
+		latest := new(types.Transaction)
+		if err := latest.UnmarshalBinary(rec.Raw); err != nil {
+			return err
+		}
+		cancelled = latest.To() == nil || *latest.To() != *msg.To || latest.Value().Cmp(msg.Value) != 0 || !bytes.Equal(latest.Data(), msg.Data)
+		p.Hash, p.Nonce, raw = rec.Hash, rec.Nonce, rec.Raw
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, p.Hash)
+	if err == nil {
+		p.Status, p.Error = PayoutMined, ""
+		if cancelled {
+			p.Status, p.Error = PayoutError, errPayoutCancelled
+		} else if receipt.Status != types.ReceiptStatusSuccessful {
+			p.Status, p.Error = PayoutReverted, "transaction reverted"
+		}
+		return nil
+	}
+	if err != ethereum.NotFound {
+		return err
+	}
+	if _, _, err := client.TransactionByHash(ctx, p.Hash); err == nil {
+		// a pending cancel is paid again once it is mined
+		p.Status, p.Error = PayoutSent, ""
+		if cancelled {
+			p.Error = errPayoutCancelled
+		}
+		return nil
+	} else if err != ethereum.NotFound {
+		return err
+	}
+
+	confirmed, err := client.NonceAt(ctx, msg.From, nil)
+	if err != nil {
+		return err
+	}
+	if p.Nonce < confirmed || len(raw) == 0 || cancelled {
+		p.Status, p.Error = PayoutError, "transaction dropped"
+		return nil
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return err
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil && !alreadyKnown(err) {
+		return fmt.Errorf("failed to rebroadcast payout %s to %s: %v, speed it up or cancel it", p.Hash.Hex(), p.Address.Hex(), err)
+	}
+	log.Printf("rebroadcast payout %s to %s\n", p.Hash.Hex(), p.Address.Hex())
+	if err := nm.Sent(msg.From, tx); err != nil {
+		log.Println("failed to record transaction: ", err)
+	}
+	p.Status, p.Error = PayoutSent, ""
+	return nil
+}
+
+// alreadyKnown tells if the node refused a transaction because it already
+// has it, the transaction is pending
+func alreadyKnown(err error) bool {
+	_, isRPC := err.(rpc.Error)
+	return isRPC && strings.Contains(err.Error(), "already known")
+}
+
+// accountSigner returns a function signing transactions of from: with the
+// agent or signer daemon if available, otherwise with the key of from
+// decrypted once, e.g. for all the transactions of a batch
+func (cli *CLI) accountSigner(from common.Address) (func(*types.Transaction, *big.Int) (*types.Transaction, error), error) {
+	if url := cli.remoteSigner(from); url != "" {
+		return func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+			return remoteSignTx(url, from, tx, chainID)
+		}, nil
+	}
+	fileName, key, _, err := cli.getAccountKey(from.Hex())
+	if err != nil {
+		return nil, err
+	}
+	log.Println("your from address filename: ", fileName)
+	hdks := hdkeystore.NewHDKeyStore(cli.DataPath, key.PrivateKey)
+	return func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+		return hdks.SignTx(from, tx, chainID)
+	}, nil
+}
+
+// BatchPay pays the rows of the payouts file from the account from, in
+// ether or in the token symbol if set, and keeps the result of every row
+// in the results file out. Rows paid or pending in out are skipped, so an
+// interrupted batch is finished by running it again.
+func (cli *CLI) BatchPay(from, file, symbol, out string, opts TxOptions, yes bool, confirmations uint64) error {
+	name := "ether"
+	var token *common.Address
+	var meta *TokenMeta
+	if symbol != "" {
+		var err error
+		if meta, err = cli.lookupToken(context.Background(), symbol); err != nil {
+			return err
+		}
+		name, token = symbol, &meta.Address
+	}
+	var decimals uint8
+	if meta != nil {
+		decimals = meta.Decimals
+	}
+	payouts, err := ReadPayouts(file, symbol != "", decimals)
+	if err != nil {
+		return err
+	}
+	results, err := readPayoutResults(out, name)
+	if err != nil {
+		return err
+	}
+
+	rclient, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	client := ethclient.NewClient(rclient)
+	ctx := context.Background()
+	fromAddr := common.HexToAddress(from)
+
+	// resume the rows of the previous run
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		return err
+	}
+	var todo []*Payout
+	for _, p := range payouts {
+		if prev, ok := results[p.Address]; ok {
+			if prev.Amount == nil || prev.Amount.Cmp(p.Amount) != 0 {
+				nm.Close()
+				return fmt.Errorf("line %d: amount %s of %s differs from %s in %s", p.Line, p.Amount, p.Address.Hex(), prev.Amount, out)
+			}
+			p.Status, p.Nonce, p.Hash, p.Error = prev.Status, prev.Nonce, prev.Hash, prev.Error
+			msg, err := payoutMsg(fromAddr, p, token)
+			if err == nil {
+				err = cli.resumePayout(ctx, client, nm, msg, p)
+			}
+			if err != nil {
+				nm.Close()
+				return err
+			}
+		}
+		if p.Status != PayoutMined && p.Status != PayoutSent {
+			todo = append(todo, p)
+		}
+	}
+	nm.Close()
+	if err := writePayoutResults(out, name, payouts); err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		log.Printf("all %d payouts of %s are sent, see %s\n", len(payouts), file, out)
+		return cli.waitPayouts(ctx, client, fromAddr, out, name, payouts, confirmations)
+	}
+
+	// fees and totals
+	id, err := cli.chainID(ctx, rclient)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		opts.Legacy = true
+	}
+	if err := fillFees(ctx, rclient, &opts); err != nil {
+		return err
+	}
+	total := new(big.Int)
+	for _, p := range todo {
+		total.Add(total, p.Amount)
+	}
+	if err := cli.checkQuorum(ctx, fromAddr, token); err != nil {
+		return err
+	}
+	balance, err := client.BalanceAt(ctx, fromAddr, nil)
+	if err != nil {
+		return err
+	}
+	gas := uint64(21000)
+	if symbol != "" {
+		gas = 65000 // a token transfer to a new holder
+	}
+	maxFee := new(big.Int).Mul(opts.GasPrice, new(big.Int).SetUint64(gas*uint64(len(todo))))
+	summary := fmt.Sprintf("from:      %s\npayouts:   %d of %d rows to pay\nmax fee:   about %s ether", fromAddr.Hex(), len(todo), len(payouts), utils.FormatEther(maxFee))
+	if symbol == "" {
+		need := new(big.Int).Add(total, maxFee)
+		summary += fmt.Sprintf("\ntotal:     %s\nbalance:   %s", formatPayout(total, nil), formatPayout(balance, nil))
+		if balance.Cmp(need) < 0 {
+			return fmt.Errorf("insufficient funds: balance %s ether, need %s ether for the payouts plus fees", utils.FormatEther(balance), utils.FormatEther(need))
+		}
+	} else {
+		tokenBalance, err := tokenBalance(ctx, client, *token, fromAddr)
+		if err != nil {
+			return err
+		}
+		summary += fmt.Sprintf("\ntotal:     %s\nbalance:   %s, %s for fees", formatPayout(total, meta), formatPayout(tokenBalance, meta), formatPayout(balance, nil))
+		if tokenBalance.Cmp(total) < 0 {
+			return fmt.Errorf("insufficient %s balance %s, need %s", symbol, formatPayout(tokenBalance, meta), formatPayout(total, meta))
+		}
+		if balance.Cmp(maxFee) < 0 {
+			return fmt.Errorf("insufficient funds for fees: balance %s ether, need about %s ether", utils.FormatEther(balance), utils.FormatEther(maxFee))
+		}
+	}
+	if !yes && !confirm("Pay?", summary) {
+		return ErrRequestDenied
+	}
+
+	// unlock once, then send with sequential nonces
+	sign, err := cli.accountSigner(fromAddr)
+	if err != nil {
+		return err
+	}
+	var sent, failed int
+	for _, p := range todo {
+		msg, err := payoutMsg(fromAddr, p, token)
+		if err != nil {
+			return err
+		}
+		tx, err := cli.signPayout(ctx, rclient, msg, opts, id, sign)
+		if err != nil {
+			p.Status, p.Error = PayoutError, err.Error()
+			failed++
+			log.Printf("line %d: failed to pay %s to %s: %v\n", p.Line, formatPayout(p.Amount, meta), p.Address.Hex(), err)
+			if err := writePayoutResults(out, name, payouts); err != nil {
+				return err
+			}
+			continue
+		}
+		// the signed transaction is recorded before the broadcast: if the
+		// batch stops then, the next run sends it again instead of paying
+		// with another one
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		p.Status, p.Nonce, p.Hash, p.Raw, p.Error = PayoutSigned, tx.Nonce(), tx.Hash(), raw, ""
+		if err := writePayoutResults(out, name, payouts); err != nil {
+			cli.releaseNonce(fromAddr, tx.Nonce())
+			return err
+		}
+		if err := client.SendTransaction(ctx, tx); err != nil && !alreadyKnown(err) {
+			if _, rejected := err.(rpc.Error); !rejected {
+				// the node may have the transaction, its nonce stays taken
+				cli.recordSent(fromAddr, tx)
+				p.Error = err.Error()
+				if err := writePayoutResults(out, name, payouts); err != nil {
+					return err
+				}
+				return fmt.Errorf("line %d: broadcast of %s failed: %v, run batchpay again to send it again", p.Line, tx.Hash().Hex(), err)
+			}
+			cli.releaseNonce(fromAddr, tx.Nonce())
+			p.Status, p.Hash, p.Raw, p.Error = PayoutError, common.Hash{}, nil, err.Error()
+			failed++
+			log.Printf("line %d: failed to pay %s to %s: %v\n", p.Line, formatPayout(p.Amount, meta), p.Address.Hex(), err)
+		} else {
+			cli.recordSent(fromAddr, tx)
+			p.Status = PayoutSent
+			sent++
+			log.Printf("line %d: paid %s to %s nonce %d: %s\n", p.Line, formatPayout(p.Amount, meta), p.Address.Hex(), tx.Nonce(), tx.Hash().Hex())
+		}
+		if err := writePayoutResults(out, name, payouts); err != nil {
+			return err
+		}
+	}
+	log.Printf("%d payouts sent, %d failed, results in %s\n", sent, failed, out)
+	if err := cli.waitPayouts(ctx, client, fromAddr, out, name, payouts, confirmations); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d payouts failed, run batchpay again to retry them", failed)
+	}
+	return nil
+}
+
+// formatPayout formats an amount of wei in ether, or of base units of the
+// token in token units
+func formatPayout(amount *big.Int, token *TokenMeta) string {
+	if token == nil {
+		return utils.FormatEther(amount) + " ether"
+	}
+	return token.Format(amount)
+}
+
+// signPayout simulates, estimates and signs the payout msg with the next
+// nonce of its sender, which stays reserved until it is broadcast
+func (cli *CLI) signPayout(ctx context.Context, rc *rpc.Client, msg ethereum.CallMsg, opts TxOptions, chainID *big.Int,
+	sign func(*types.Transaction, *big.Int) (*types.Transaction, error)) (*types.Transaction, error) {
+	if err := preflight(ctx, rc, msg, opts.Force); err != nil {
+		return nil, err
+	}
+	client := ethclient.NewClient(rc)
+	gas, err := client.EstimateGas(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+	opts.Gas = gas
+	nonce, err := cli.reserveNonce(ctx, client, msg.From, false)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := sign(newTx(nonce, msg, opts, chainID), chainID)
+	if err != nil {
+		cli.releaseNonce(msg.From, nonce)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// waitPayouts waits for confirmations of the payouts sent from from and
+// records whether they were mined or reverted. A payout replaced by another
+// transaction is checked again by the next run, it may have been sped up.
+func (cli *CLI) waitPayouts(ctx context.Context, client *ethclient.Client, from common.Address, out, token string, payouts []*Payout, confirmations uint64) error {
+	if confirmations == 0 {
+		return nil
+	}
+	var reverted int
+	for _, p := range payouts {
+		if p.Status != PayoutSent {
+			continue
+		}
+		receipt, err := cli.waitReceipt(ctx, client, from, p.Nonce, p.Hash, confirmations)
+		if err != nil && err != errTxReplaced {
+			return err
+		}
+		p.Status = PayoutMined
+		if err == errTxReplaced {
+			p.Status, p.Error = PayoutError, "transaction "+err.Error()
+			reverted++
+		} else if p.Error == errPayoutCancelled {
+			p.Status = PayoutError
+			reverted++
+		} else if receipt.Status != types.ReceiptStatusSuccessful {
+			p.Status, p.Error = PayoutReverted, "transaction reverted"
+			reverted++
+		}
+		if err := writePayoutResults(out, token, payouts); err != nil {
+			return err
+		}
+	}
+	if reverted > 0 {
+		return fmt.Errorf("%d payouts reverted, cancelled or replaced, run batchpay again to retry them", reverted)
+	}
+	log.Printf("all payouts mined, results in %s\n", out)
+	return nil
+}
@@ -3,7 +3,6 @@ package client
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -11,13 +10,15 @@ import (
 	"math/big"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"wallet/abi"
 	"wallet/hdkeystore"
 	"wallet/hdwallet"
 	"wallet/utils"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -31,12 +32,37 @@ type CLI struct {
 	DataPath   string
 	NetworkURL string
 	TokensFile string
+	// NetworksFile lists the network profiles, Network is the selected one
+	NetworksFile string
+	Network      *Network
+	// SignerURL is the unix socket or localhost http url of a signer
+	// daemon, when set transactions are signed by the daemon
+	SignerURL string
+	// Unprotected allows signing without replay protection on nodes
+	// without eth_chainId when the network has no chain id
+	Unprotected bool
+	// WaitTimeout limits how long -wait waits for a transaction, 0 for
+	// no limit
+	WaitTimeout time.Duration
+
+	// rc is the connection to the node shared by the command, see rpcClient
+	rc        *rpc.Client
+	transport string
+	subscribe *bool
+	// endpoints are the checked nodes of a network with several endpoints
+	endpoints []*Endpoint
 }
 
-// TokenConfig ...
+// TokenConfig is a token of the registry, see registry.go
 type TokenConfig struct {
 	Symbol string `json:"symbol"`
 	Addr   string `json:"addr"`
+	// Alias names the token instead of its symbol, to tell apart tokens
+	// with the same symbol
+	Alias string `json:"alias,omitempty"`
+	// Name and Decimals are cached from the contract
+	Name     string `json:"name,omitempty"`
+	Decimals *uint8 `json:"decimals,omitempty"`
 }
 
 // NewCLI ...
@@ -45,17 +71,54 @@ func NewCLI(path, url string) *CLI {
 		DataPath:   path,
 		NetworkURL: url,
 		TokensFile: "tokens.json",
+		// 没有 networks.json 时的网络, 不检查 chain id
+		NetworksFile: "networks.json",
+		Network:      &Network{RPC: url, CoinType: defaultCoinType},
+		SignerURL:    os.Getenv("WALLET_SIGNER"),
+		WaitTimeout:  30 * time.Minute,
 	}
 }
 
 // Usage ...
 func (cli *CLI) Usage() {
+	fmt.Println("./wallet [-network NAME] [-quorum N] [-waittimeout 30m] [-unprotected] COMMAND ... -- for run COMMAND on a network of networks.json, its default network if not set")
+	fmt.Println("./wallet networks -- for list the networks of networks.json")
+	fmt.Println("./wallet endpoints -- for check the chain id, block height and latency of the endpoints of the network")
 	fmt.Println("./wallet createwallet -name HDWALLET_NAME -- for create a new wallet")
-	fmt.Println("./wallet balance -addr ACCOUNT_ADDRSS -- for get ether balance of a address")
-	fmt.Println("./wallet transfer -from ACCOUNT_ADDRESS -to ADDRESS -value VALUE -- for send ether to ADDRESS")
-	fmt.Println("./wallet addtoken -addr CONTRACT_ADDRSS -- for add token symbol")
-	fmt.Println("./wallet tokenbalance -addr ACCOUNT_ADDRESS -symbol TOKEN -- for get token balances")
-	fmt.Println("./wallet sendtoken -from ACCOUNT_ADDRESS -symbol SYMBOL -to ADDRESS -value VALUE -- for send tokens to ADDRESS")
+	fmt.Println("./wallet balance -addr ACCOUNT_ADDRSS [-wei] -- for get ether balance of a address")
+	fmt.Println("./wallet transfer -from ACCOUNT_ADDRESS -to ADDRESS -value 1.5ether|20gwei|300000wei [-gas N] [-gasprice 20gwei | -speed slow|normal|fast] [-data 0x..] [-legacy] [-force] [-y] [-wait] [-confirmations N] -- for send ether to ADDRESS")
+	fmt.Println("./wallet addtoken -addr CONTRACT_ADDRSS [-alias NAME] [-selectors] [-force] -- for add an ERC-20 token to the registry of the network, -alias for a symbol already registered")
+	fmt.Println("./wallet tokens list -- for list the tokens of the registry of the network")
+	fmt.Println("./wallet tokens remove -symbol SYMBOL|ALIAS|ADDRESS -- for remove a token from the registry")
+	fmt.Println("./wallet tokens info -symbol SYMBOL|ALIAS|ADDRESS -- for print the name, decimals, total supply and code of a token")
+	fmt.Println("./wallet portfolio -name HDWALLET_NAME [-block N] [-json | -csv] [-workers 8] -- for list the ether and token balances of all accounts of a wallet")
+	fmt.Println("./wallet tokenbalance -addr ACCOUNT_ADDRESS -symbol TOKEN [-raw] -- for get token balances in token units")
+	fmt.Println("./wallet sendtoken -from ACCOUNT_ADDRESS -symbol SYMBOL -to ADDRESS -value 12.5 [-speed normal] [-legacy] [-force] [-y] [-wait] [-confirmations N] -- for send tokens to ADDRESS")
+	fmt.Println("./wallet approve -from ACCOUNT_ADDRESS -symbol SYMBOL -spender ADDRESS -value 12.5|max [-speed normal] [-legacy] [-force] [-y] [-wait] -- for allow ADDRESS to transfer tokens of the account")
+	fmt.Println("./wallet revoke -from ACCOUNT_ADDRESS -symbol SYMBOL -spender ADDRESS [-speed normal] [-legacy] [-force] [-y] [-wait] -- for set the allowance of ADDRESS to 0")
+	fmt.Println("./wallet allowance -owner ADDRESS -spender ADDRESS -symbol SYMBOL -- for get the tokens of owner that spender can transfer")
+	fmt.Println("./wallet transferfrom -from SPENDER_ADDRESS -owner ADDRESS -to ADDRESS -symbol SYMBOL -value 12.5 [-speed normal] [-legacy] [-force] [-y] [-wait] -- for transfer tokens of owner with the allowance of the account SPENDER_ADDRESS")
+	fmt.Println("./wallet allowances -name HDWALLET_NAME [-fromblock N] [-json] -- for list the outstanding approvals of the accounts of a wallet")
+	fmt.Println("./wallet watch -name HDWALLET_NAME [-fromblock N] [-confirmations 12] -- for print the ether and token transfers of the accounts of a wallet as they are mined")
+	fmt.Println("./wallet audit -name HDWALLET_NAME [-decrypt] [-json] -- for check the keystore files of a wallet")
+	fmt.Println("./wallet backup -name HDWALLET_NAME -out FILE -- for write an encrypted backup of a wallet")
+	fmt.Println("./wallet restore -in FILE [-name HDWALLET_NAME] [-force] -- for restore a wallet from a backup")
+	fmt.Println("./wallet signer -name HDWALLET_NAME [-mnemonic] [-socket FILE | -http 127.0.0.1:8550] [-rules FILE] -- for run the signing daemon, set WALLET_SIGNER to use it")
+	fmt.Println("./wallet agent -name HDWALLET_NAME [-socket FILE] -- for run the session agent, set WALLET_AGENT_SOCK to use it")
+	fmt.Println("./wallet unlock -addr ACCOUNT_ADDRESS [-for 15m] -- for unlock an account in the agent")
+	fmt.Println("./wallet lock [-addr ACCOUNT_ADDRESS] -- for lock one or all accounts in the agent")
+	fmt.Println("./wallet status -- for list the accounts of the agent")
+	fmt.Println("./wallet migrate -name HDWALLET_NAME -to db|file [-remove] -- for move the keys of a wallet between key files and a database")
+	fmt.Println("./wallet buildtx -from ACCOUNT_ADDRESS -to ADDRESS -value VALUE [-symbol SYMBOL] [-gas N] [-gasprice PRICE | -speed normal] [-data 0x..] [-legacy] [-force] [-out tx.json] -- for build an unsigned transaction online")
+	fmt.Println("./wallet signtx [-in tx.json] [-out tx.signed] [-mnemonic [-hd 10]] [-y] -- for sign a transaction offline")
+	fmt.Println("./wallet broadcast [-in tx.signed] -- for submit a signed transaction")
+	fmt.Println("./wallet nonces -addr ACCOUNT_ADDRESS [-release NONCE] -- for list the nonces and transactions sent from an account, or free a nonce reserved by buildtx")
+	fmt.Println("./wallet speedup -hash TX_HASH [-gasprice PRICE | -speed normal] [-force] [-y] -- for resend a pending transaction with a higher fee")
+	fmt.Println("./wallet cancel -hash TX_HASH [-gasprice PRICE | -speed normal] [-y] -- for replace a pending transaction with an empty one")
+	fmt.Println("./wallet receipt -hash TX_HASH -- for print the status, fee and token transfers of a transaction")
+	fmt.Println("./wallet call -to CONTRACT -abi FILE.json -method METHOD [-args a,b,[c,d]] [-from ADDRESS] -- for call a read only contract method")
+	fmt.Println("./wallet send -from ACCOUNT_ADDRESS -to CONTRACT -abi FILE.json -method METHOD [-args a,b,[c,d]] [-value VALUE] [-gas N] [-gasprice PRICE | -speed normal] [-legacy] [-force] [-y] [-wait] [-confirmations N] -- for send a contract transaction")
+	fmt.Println("./wallet batchpay -from ACCOUNT_ADDRESS -file payouts.csv [-symbol SYMBOL] [-out FILE] [-gasprice PRICE | -speed normal] [-legacy] [-force] [-y] [-wait] [-confirmations N] -- for pay the address,amount rows of a csv file")
 }
 
 func (cli *CLI) validateArgs() {
@@ -65,10 +128,46 @@ func (cli *CLI) validateArgs() {
 	}
 }
 
+// offlineCommands don't use the node, the chain id is not checked. The
+// endpoints command checks the chain id of every endpoint itself.
+var offlineCommands = map[string]bool{
+	"createwallet": true, "audit": true, "backup": true, "restore": true, "signer": true, "agent": true,
+	"unlock": true, "lock": true, "status": true, "migrate": true, "signtx": true, "networks": true,
+	"endpoints": true, "tokens list": true, "tokens remove": true,
+}
+
 // Run ...
 func (cli *CLI) Run() {
+	// 全局参数: ./wallet -network NAME COMMAND ...
+	global := flag.NewFlagSet("wallet", flag.ExitOnError)
+	globalNetwork := global.String("network", os.Getenv("WALLET_NETWORK"), "NETWORK name of "+cli.NetworksFile)
+	globalQuorum := global.Int("quorum", 0, "number of endpoints that must agree on balances and nonces, overrides the network's quorum")
+	global.BoolVar(&cli.Unprotected, "unprotected", false, "sign without replay protection (EIP-155) when neither the network nor the node has a chain id")
+	global.DurationVar(&cli.WaitTimeout, "waittimeout", cli.WaitTimeout, "how long -wait waits for a transaction to be confirmed, 0 for no limit")
+	global.Usage = cli.Usage
+	if err := global.Parse(os.Args[1:]); err != nil {
+		log.Panic("failed to Parse global params:", err)
+	}
+	os.Args = append(os.Args[:1], global.Args()...)
 	cli.validateArgs()
 
+	if err := cli.UseNetwork(*globalNetwork); err != nil {
+		log.Fatal(err)
+	}
+	if *globalQuorum > 0 {
+		if n := len(cli.Network.Endpoints); *globalQuorum > n && *globalQuorum > 1 {
+			log.Fatalf("-quorum %d with %d endpoints", *globalQuorum, n)
+		}
+		cli.Network.Quorum = *globalQuorum
+	}
+	// 同一个命令共用一个节点连接
+	defer cli.Close()
+	if !offlineCommands[os.Args[1]] && !(len(os.Args) > 2 && offlineCommands[os.Args[1]+" "+os.Args[2]]) {
+		if err := cli.checkChainID(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// 绑定
 	createwalletcmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	// 假使不使用 createwallet -name eilinge, 则会传递该默认值
@@ -76,28 +175,255 @@ func (cli *CLI) Run() {
 
 	balancecmd := flag.NewFlagSet("balance", flag.ExitOnError)
 	balancecmdAcct := balancecmd.String("addr", "", "ACCOUNT_NAME")
+	balancecmdWei := balancecmd.Bool("wei", false, "also print the exact balance in wei")
 
 	// transfer -from address -to ADDRESS -value VALUE -- for send ether to ADDRESS
 	transfer := flag.NewFlagSet("transfer", flag.ExitOnError)
 	transferFrom := transfer.String("from", "", "from_Address")
 	transferTo := transfer.String("to", "", "to_Address")
-	transferValue := transfer.Int64("value", 0, "Value")
+	transferValue := transfer.String("value", "", "VALUE with unit, e.g. 1.5ether, 20gwei, 300000wei")
+	transferYes := transfer.Bool("y", false, "send without asking for confirmation")
+	transferGas := transfer.Uint64("gas", 0, "GAS_LIMIT, estimated if not set")
+	transferGasPrice := transfer.String("gasprice", "", "GAS_PRICE with unit, e.g. 20gwei")
+	transferData := transfer.String("data", "", "hex DATA")
+	transferSpeed := transfer.String("speed", "normal", "gas price strategy slow, normal or fast")
+	transferLegacy := transfer.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	transferForce := transfer.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	transferWait := transfer.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+	transferConfirmations := transfer.Uint64("confirmations", 0, "number of confirmations to wait for, implies -wait")
 
 	// addtoken -addr CONTRACT_ADDR
 	addtoken := flag.NewFlagSet("addtoken", flag.ExitOnError)
 	tokenAddr := addtoken.String("addr", "", "Contact_Address")
+	tokenAlias := addtoken.String("alias", "", "NAME of the token instead of its symbol")
+	addtokenSelectors := addtoken.Bool("selectors", false, "also require the ERC-20 function selectors in the bytecode, proxies fail it")
+	addtokenForce := addtoken.Bool("force", false, "add a contract that fails the ERC-20 checks, never an address without code")
+
+	// tokens list|remove|info -symbol SYMBOL -- manage the token registry
+	tokensList := flag.NewFlagSet("tokens list", flag.ExitOnError)
+	tokensRemove := flag.NewFlagSet("tokens remove", flag.ExitOnError)
+	tokensRemoveSymbol := tokensRemove.String("symbol", "", "TOKEN symbol, alias or address")
+	tokensInfo := flag.NewFlagSet("tokens info", flag.ExitOnError)
+	tokensInfoSymbol := tokensInfo.String("symbol", "", "TOKEN symbol, alias or address")
 
 	// tokenbalance -addr ACCOUNT_NAME -- for get token balances
 	token := flag.NewFlagSet("tokenbalance", flag.ExitOnError)
 	addr := token.String("addr", "", "Contact_Address")
 	symbol := token.String("symbol", "", "TOKEN_SYMBOL")
+	tokenRaw := token.Bool("raw", false, "also print the balance in base units")
 
 	// sendtoken -addr ACCOUNT_NAME -symbol SYMBOL -toaddress ADDRESS -value VALUE
 	sendtoken := flag.NewFlagSet("sendtoken", flag.ExitOnError)
 	fromAddr := sendtoken.String("from", "", "Contact_Address")
 	sendSymbol := sendtoken.String("symbol", "", "TOKEN_SYMBOL")
 	toAddr := sendtoken.String("to", "", "Contact_Address")
-	tokenValue := sendtoken.Int64("value", 0, "TOKEN_VALUE")
+	tokenValue := sendtoken.String("value", "", "TOKEN_VALUE in token units, e.g. 12.5")
+	tokenSpeed := sendtoken.String("speed", "normal", "gas price strategy slow, normal or fast")
+	tokenLegacy := sendtoken.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	tokenForce := sendtoken.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	tokenYes := sendtoken.Bool("y", false, "send without asking for confirmation")
+	tokenWait := sendtoken.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+	tokenConfirmations := sendtoken.Uint64("confirmations", 0, "number of confirmations to wait for, implies -wait")
+
+	// audit -name HDWALLET_NAME -- for check the keystore files of a wallet
+	audit := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditName := audit.String("name", "", "HDWALLET_NAME")
+	auditDecrypt := audit.Bool("decrypt", false, "decrypt the keys with the wallet password")
+	auditJSON := audit.Bool("json", false, "print the report as json")
+
+	// backup -name HDWALLET_NAME -out FILE
+	backup := flag.NewFlagSet("backup", flag.ExitOnError)
+	backupName := backup.String("name", "", "HDWALLET_NAME")
+	backupOut := backup.String("out", "", "BACKUP_FILE")
+
+	// restore -in FILE -- restore the wallet stored in the backup
+	restore := flag.NewFlagSet("restore", flag.ExitOnError)
+	restoreIn := restore.String("in", "", "BACKUP_FILE")
+	restoreName := restore.String("name", "", "HDWALLET_NAME, default the name stored in the backup")
+	restoreForce := restore.Bool("force", false, "overwrite existing accounts")
+
+	// signer -name HDWALLET_NAME -- hold the keys and sign over account_* json-rpc
+	signer := flag.NewFlagSet("signer", flag.ExitOnError)
+	signerName := signer.String("name", "", "HDWALLET_NAME")
+	signerMnemonic := signer.Bool("mnemonic", false, "also hold the hd accounts of a mnemonic")
+	signerHD := signer.Int("hd", 10, "number of hd accounts to derive from the mnemonic")
+	signerSocket := signer.String("socket", cli.DataPath+"/signer.ipc", "UNIX_SOCKET")
+	signerHTTP := signer.String("http", "", "localhost http address instead of the unix socket")
+	signerRules := signer.String("rules", "", "RULE_FILE")
+
+	// agent -name HDWALLET_NAME -- hold unlocked accounts for a session
+	agent := flag.NewFlagSet("agent", flag.ExitOnError)
+	agentName := agent.String("name", "", "HDWALLET_NAME")
+	agentSocket := agent.String("socket", cli.DataPath+"/agent.ipc", "UNIX_SOCKET")
+
+	// unlock -addr ACCOUNT_ADDRESS -for 15m
+	unlock := flag.NewFlagSet("unlock", flag.ExitOnError)
+	unlockAddr := unlock.String("addr", "", "ACCOUNT_ADDRESS")
+	unlockFor := unlock.Duration("for", 15*time.Minute, "unlock duration, 0 until the agent exits")
+
+	// lock -addr ACCOUNT_ADDRESS, all accounts without -addr
+	lock := flag.NewFlagSet("lock", flag.ExitOnError)
+	lockAddr := lock.String("addr", "", "ACCOUNT_ADDRESS")
+
+	status := flag.NewFlagSet("status", flag.ExitOnError)
+
+	// migrate -name HDWALLET_NAME -to db|file -- move the keys to another storage
+	migrate := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateName := migrate.String("name", "", "HDWALLET_NAME")
+	migrateTo := migrate.String("to", "db", "STORAGE db or file")
+	migrateRemove := migrate.Bool("remove", false, "remove the source keys after the migration")
+
+	// buildtx -from ACCOUNT_ADDRESS -to ADDRESS -value VALUE -out FILE -- online, unsigned transaction
+	buildtx := flag.NewFlagSet("buildtx", flag.ExitOnError)
+	buildFrom := buildtx.String("from", "", "from_Address")
+	buildTo := buildtx.String("to", "", "to_Address")
+	buildValue := buildtx.String("value", "", "VALUE with unit for ether, e.g. 1.5ether, in token units with -symbol, e.g. 12.5")
+	buildSymbol := buildtx.String("symbol", "", "TOKEN symbol for a token transfer")
+	buildGas := buildtx.Uint64("gas", 0, "GAS_LIMIT, estimated if not set")
+	buildGasPrice := buildtx.String("gasprice", "", "GAS_PRICE with unit, e.g. 20gwei")
+	buildData := buildtx.String("data", "", "hex DATA")
+	buildSpeed := buildtx.String("speed", "normal", "gas price strategy slow, normal or fast")
+	buildLegacy := buildtx.Bool("legacy", false, "build a legacy transaction instead of an EIP-1559 one")
+	buildForce := buildtx.Bool("force", false, "build even if the eth_call simulation of the transaction fails")
+	buildOut := buildtx.String("out", "tx.json", "FILE of the unsigned transaction")
+
+	// signtx -in FILE -out FILE -- offline, sign with the keystore or the hd wallet
+	signtx := flag.NewFlagSet("signtx", flag.ExitOnError)
+	signIn := signtx.String("in", "tx.json", "FILE of the unsigned transaction")
+	signOut := signtx.String("out", "tx.signed", "FILE of the raw signed transaction")
+	signMnemonic := signtx.Bool("mnemonic", false, "sign with the hd wallet of a mnemonic")
+	signHD := signtx.Int("hd", 10, "number of hd accounts derived from the mnemonic")
+	signYes := signtx.Bool("y", false, "sign without asking for confirmation")
+
+	// broadcast -in FILE -- online, submit a raw signed transaction
+	broadcast := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	broadcastIn := broadcast.String("in", "tx.signed", "FILE of the raw signed transaction")
+
+	// nonces -addr ACCOUNT_ADDRESS
+	nonces := flag.NewFlagSet("nonces", flag.ExitOnError)
+	noncesAddr := nonces.String("addr", "", "ACCOUNT_ADDRESS")
+	noncesRelease := nonces.Int64("release", -1, "NONCE reserved by buildtx to free, when its transaction won't be broadcast")
+
+	// speedup -hash TX_HASH, cancel -hash TX_HASH
+	speedup := flag.NewFlagSet("speedup", flag.ExitOnError)
+	speedupHash := speedup.String("hash", "", "TX_HASH of the pending transaction")
+	speedupGasPrice := speedup.String("gasprice", "", "gas price, max fee per gas of EIP-1559 transactions, e.g. 30gwei")
+	speedupSpeed := speedup.String("speed", "normal", "fee strategy: slow, normal or fast")
+	speedupYes := speedup.Bool("y", false, "send without asking for confirmation")
+	speedupForce := speedup.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	cancel := flag.NewFlagSet("cancel", flag.ExitOnError)
+	cancelHash := cancel.String("hash", "", "TX_HASH of the pending transaction")
+	cancelGasPrice := cancel.String("gasprice", "", "gas price, max fee per gas of EIP-1559 transactions, e.g. 30gwei")
+	cancelSpeed := cancel.String("speed", "normal", "fee strategy: slow, normal or fast")
+	cancelYes := cancel.Bool("y", false, "send without asking for confirmation")
+
+	// receipt -hash TX_HASH
+	receipt := flag.NewFlagSet("receipt", flag.ExitOnError)
+	receiptHash := receipt.String("hash", "", "TX_HASH")
+
+	// call -to CONTRACT -abi FILE -method METHOD -args ARGS
+	call := flag.NewFlagSet("call", flag.ExitOnError)
+	callTo := call.String("to", "", "CONTRACT address")
+	callABI := call.String("abi", "", "abi json FILE of the contract")
+	callMethod := call.String("method", "", "METHOD name")
+	callArgs := call.String("args", "", "comma separated ARGS, arrays in brackets, e.g. 0xabc..,100,[1,2]")
+	callFrom := call.String("from", "", "ADDRESS of msg.sender")
+
+	// send -from ACCOUNT_ADDRESS -to CONTRACT -abi FILE -method METHOD -args ARGS
+	send := flag.NewFlagSet("send", flag.ExitOnError)
+	sendFrom := send.String("from", "", "ACCOUNT_ADDRESS")
+	sendTo := send.String("to", "", "CONTRACT address")
+	sendABI := send.String("abi", "", "abi json FILE of the contract")
+	sendMethod := send.String("method", "", "METHOD name")
+	sendArgs := send.String("args", "", "comma separated ARGS, arrays in brackets, e.g. 0xabc..,100,[1,2]")
+	sendValue := send.String("value", "0", "VALUE with unit for payable methods, e.g. 1.5ether")
+	sendGas := send.Uint64("gas", 0, "GAS_LIMIT, estimated if not set")
+	sendGasPrice := send.String("gasprice", "", "GAS_PRICE with unit, e.g. 20gwei")
+	sendSpeed := send.String("speed", "normal", "gas price strategy slow, normal or fast")
+	sendLegacy := send.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	sendForce := send.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	sendYes := send.Bool("y", false, "send without asking for confirmation")
+	sendWait := send.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+	sendConfirmations := send.Uint64("confirmations", 0, "number of confirmations to wait for, implies -wait")
+
+	// batchpay -from ACCOUNT_ADDRESS -file payouts.csv
+	batchpay := flag.NewFlagSet("batchpay", flag.ExitOnError)
+	batchFrom := batchpay.String("from", "", "ACCOUNT_ADDRESS paying")
+	batchFile := batchpay.String("file", "", "csv FILE of address,amount rows")
+	batchSymbol := batchpay.String("symbol", "", "TOKEN_SYMBOL to pay tokens instead of ether")
+	batchOut := batchpay.String("out", "", "results csv FILE, FILE.results.csv by default")
+	batchGasPrice := batchpay.String("gasprice", "", "GAS_PRICE with unit, e.g. 20gwei")
+	batchSpeed := batchpay.String("speed", "normal", "gas price strategy slow, normal or fast")
+	batchLegacy := batchpay.Bool("legacy", false, "send legacy transactions instead of EIP-1559 ones")
+	batchForce := batchpay.Bool("force", false, "pay even if the eth_call simulation of a payout fails")
+	batchYes := batchpay.Bool("y", false, "pay without asking for confirmation")
+	batchWait := batchpay.Bool("wait", false, "wait until the payouts are mined")
+	batchConfirmations := batchpay.Uint64("confirmations", 0, "number of confirmations to wait for, implies -wait")
+
+	// portfolio -name HDWALLET_NAME -- balances of all accounts of a wallet
+	portfolio := flag.NewFlagSet("portfolio", flag.ExitOnError)
+	portfolioName := portfolio.String("name", "", "HDWALLET_NAME")
+	portfolioBlock := portfolio.Int64("block", -1, "BLOCK number of historical balances, the latest block if not set")
+	portfolioJSON := portfolio.Bool("json", false, "print the portfolio as json")
+	portfolioCSV := portfolio.Bool("csv", false, "print the portfolio as csv")
+	portfolioWorkers := portfolio.Int("workers", defaultPortfolioWorkers, "number of concurrent balance queries")
+
+	// approve -from ACCOUNT_ADDRESS -symbol SYMBOL -spender ADDRESS -value VALUE
+	approve := flag.NewFlagSet("approve", flag.ExitOnError)
+	approveFrom := approve.String("from", "", "ACCOUNT_ADDRESS owning the tokens")
+	approveSymbol := approve.String("symbol", "", "TOKEN_SYMBOL")
+	approveSpender := approve.String("spender", "", "ADDRESS allowed to transfer the tokens")
+	approveValue := approve.String("value", "", "allowance in token units, e.g. 12.5, max for unlimited")
+	approveSpeed := approve.String("speed", "normal", "gas price strategy slow, normal or fast")
+	approveLegacy := approve.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	approveForce := approve.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	approveYes := approve.Bool("y", false, "send without asking for confirmation")
+	approveWait := approve.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+
+	// revoke -from ACCOUNT_ADDRESS -symbol SYMBOL -spender ADDRESS
+	revoke := flag.NewFlagSet("revoke", flag.ExitOnError)
+	revokeFrom := revoke.String("from", "", "ACCOUNT_ADDRESS owning the tokens")
+	revokeSymbol := revoke.String("symbol", "", "TOKEN_SYMBOL")
+	revokeSpender := revoke.String("spender", "", "ADDRESS whose allowance is revoked")
+	revokeSpeed := revoke.String("speed", "normal", "gas price strategy slow, normal or fast")
+	revokeLegacy := revoke.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	revokeForce := revoke.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	revokeYes := revoke.Bool("y", false, "send without asking for confirmation")
+	revokeWait := revoke.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+
+	// allowance -owner ADDRESS -spender ADDRESS -symbol SYMBOL
+	allowance := flag.NewFlagSet("allowance", flag.ExitOnError)
+	allowanceOwner := allowance.String("owner", "", "ADDRESS owning the tokens")
+	allowanceSpender := allowance.String("spender", "", "ADDRESS allowed to transfer the tokens")
+	allowanceSymbol := allowance.String("symbol", "", "TOKEN_SYMBOL")
+
+	// transferfrom -from SPENDER_ADDRESS -owner ADDRESS -to ADDRESS -symbol SYMBOL -value VALUE
+	transferfrom := flag.NewFlagSet("transferfrom", flag.ExitOnError)
+	transferfromFrom := transferfrom.String("from", "", "ACCOUNT_ADDRESS of the spender sending the transaction")
+	transferfromOwner := transferfrom.String("owner", "", "ADDRESS owning the tokens")
+	transferfromTo := transferfrom.String("to", "", "ADDRESS receiving the tokens")
+	transferfromSymbol := transferfrom.String("symbol", "", "TOKEN_SYMBOL")
+	transferfromValue := transferfrom.String("value", "", "TOKEN_VALUE in token units, e.g. 12.5")
+	transferfromSpeed := transferfrom.String("speed", "normal", "gas price strategy slow, normal or fast")
+	transferfromLegacy := transferfrom.Bool("legacy", false, "send a legacy transaction instead of an EIP-1559 one")
+	transferfromForce := transferfrom.Bool("force", false, "send even if the eth_call simulation of the transaction fails")
+	transferfromYes := transferfrom.Bool("y", false, "send without asking for confirmation")
+	transferfromWait := transferfrom.Bool("wait", false, "wait until the transaction is mined and print its receipt")
+
+	// allowances -name HDWALLET_NAME -- outstanding approvals of a wallet
+	allowances := flag.NewFlagSet("allowances", flag.ExitOnError)
+	allowancesName := allowances.String("name", "", "HDWALLET_NAME")
+	allowancesFrom := allowances.Uint64("fromblock", 0, "BLOCK number to search the Approval events from")
+	allowancesJSON := allowances.Bool("json", false, "print the approvals as json")
+
+	// watch -name HDWALLET_NAME -- live transfers of a wallet
+	watch := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchName := watch.String("name", "", "HDWALLET_NAME")
+	watchFrom := watch.Int64("fromblock", -1, "BLOCK number to start from, after the last block watched if not set")
+	watchConfirmations := watch.Uint64("confirmations", defaultWatchConfirmations, "number of blocks on top of a block before its transfers are printed, 0 to print them at once")
+
+	networks := flag.NewFlagSet("networks", flag.ExitOnError)
+	endpoints := flag.NewFlagSet("endpoints", flag.ExitOnError)
 
 	switch os.Args[1] {
 	case "createwallet":
@@ -128,6 +454,28 @@ func (cli *CLI) Run() {
 			log.Panic("failed to Parse addtoken params:", err)
 		}
 
+	case "tokens":
+		if len(os.Args) < 3 {
+			cli.Usage()
+			os.Exit(1)
+		}
+		var err error
+		switch os.Args[2] {
+		case "list":
+			err = tokensList.Parse(os.Args[3:])
+		case "remove":
+			err = tokensRemove.Parse(os.Args[3:])
+		case "info":
+			err = tokensInfo.Parse(os.Args[3:])
+		default:
+			cli.Usage()
+			os.Exit(1)
+		}
+
+		if err != nil {
+			log.Panic("failed to Parse tokens params:", err)
+		}
+
 	case "tokenbalance":
 		err := token.Parse(os.Args[2:])
 
@@ -142,6 +490,202 @@ func (cli *CLI) Run() {
 			log.Panic("failed to Parse sendtoken params:", err)
 		}
 
+	case "audit":
+		err := audit.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse audit params:", err)
+		}
+
+	case "backup":
+		err := backup.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse backup params:", err)
+		}
+
+	case "restore":
+		err := restore.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse restore params:", err)
+		}
+
+	case "signer":
+		err := signer.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse signer params:", err)
+		}
+
+	case "agent":
+		err := agent.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse agent params:", err)
+		}
+
+	case "unlock":
+		err := unlock.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse unlock params:", err)
+		}
+
+	case "lock":
+		err := lock.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse lock params:", err)
+		}
+
+	case "status":
+		err := status.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse status params:", err)
+		}
+
+	case "migrate":
+		err := migrate.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse migrate params:", err)
+		}
+
+	case "buildtx":
+		err := buildtx.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse buildtx params:", err)
+		}
+
+	case "signtx":
+		err := signtx.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse signtx params:", err)
+		}
+
+	case "broadcast":
+		err := broadcast.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse broadcast params:", err)
+		}
+
+	case "nonces":
+		err := nonces.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse nonces params:", err)
+		}
+
+	case "speedup":
+		err := speedup.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse speedup params:", err)
+		}
+
+	case "cancel":
+		err := cancel.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse cancel params:", err)
+		}
+
+	case "receipt":
+		err := receipt.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse receipt params:", err)
+		}
+
+	case "call":
+		err := call.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse call params:", err)
+		}
+
+	case "send":
+		err := send.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse send params:", err)
+		}
+
+	case "batchpay":
+		err := batchpay.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse batchpay params:", err)
+		}
+
+	case "portfolio":
+		err := portfolio.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse portfolio params:", err)
+		}
+
+	case "approve":
+		err := approve.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse approve params:", err)
+		}
+
+	case "revoke":
+		err := revoke.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse revoke params:", err)
+		}
+
+	case "allowance":
+		err := allowance.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse allowance params:", err)
+		}
+
+	case "transferfrom":
+		err := transferfrom.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse transferfrom params:", err)
+		}
+
+	case "allowances":
+		err := allowances.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse allowances params:", err)
+		}
+
+	case "watch":
+		err := watch.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse watch params:", err)
+		}
+
+	case "networks":
+		err := networks.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse networks params:", err)
+		}
+
+	case "endpoints":
+		err := endpoints.Parse(os.Args[2:])
+
+		if err != nil {
+			log.Panic("failed to Parse endpoints params:", err)
+		}
+
 	default:
 		cli.Usage()
 		os.Exit(1)
@@ -169,17 +713,27 @@ func (cli *CLI) Run() {
 
 	if balancecmd.Parsed() {
 		if *balancecmdAcct != "" {
-			cli.GetBalance(*balancecmdAcct)
+			cli.GetBalance(*balancecmdAcct, *balancecmdWei)
 		}
 
 	}
 
 	if transfer.Parsed() {
-		if *transferFrom == "" || *transferTo == "" || *transferValue == 0 {
+		if *transferFrom == "" || *transferTo == "" || *transferValue == "" {
 			log.Fatal("transfer parames failed")
 		}
+		value, err := utils.ParseAmount(*transferValue)
+		if err != nil || value.Sign() == 0 {
+			log.Fatal("transfer parames failed: invalid value ", *transferValue)
+		}
 
-		cli.Transfer(*transferFrom, *transferTo, *transferValue)
+		opts, err := ParseTxOptions(*transferGas, *transferGasPrice, *transferData, *transferSpeed, *transferLegacy)
+		if err != nil {
+			log.Fatal("transfer parames failed: ", err)
+		}
+		opts.Force = *transferForce
+
+		cli.Transfer(*transferFrom, *transferTo, value, opts, *transferYes, confirmationsFlag(*transferWait, *transferConfirmations))
 	}
 
 	if addtoken.Parsed() {
@@ -187,7 +741,32 @@ func (cli *CLI) Run() {
 			log.Fatal("addtoken parames failed")
 		}
 
-		cli.Addtoken(*tokenAddr)
+		if err := cli.Addtoken(*tokenAddr, *tokenAlias, *addtokenSelectors, *addtokenForce); err != nil {
+			log.Fatal("failed to addtoken: ", err)
+		}
+	}
+
+	// tokens
+	if tokensList.Parsed() {
+		if err := cli.ListTokens(); err != nil {
+			log.Fatal("failed to list tokens: ", err)
+		}
+	}
+	if tokensRemove.Parsed() {
+		if *tokensRemoveSymbol == "" {
+			log.Fatal("tokens remove parames failed")
+		}
+		if err := cli.RemoveToken(*tokensRemoveSymbol); err != nil {
+			log.Fatal("failed to remove token: ", err)
+		}
+	}
+	if tokensInfo.Parsed() {
+		if *tokensInfoSymbol == "" {
+			log.Fatal("tokens info parames failed")
+		}
+		if err := cli.TokenInfo(*tokensInfoSymbol); err != nil {
+			log.Fatal("failed to get token info: ", err)
+		}
 	}
 
 	// tokenbalance
@@ -196,16 +775,444 @@ func (cli *CLI) Run() {
 			log.Fatal("tokenbalance parames failed")
 		}
 
-		cli.TokenBalance(*addr, *symbol)
+		cli.TokenBalance(*addr, *symbol, *tokenRaw)
 	}
 
 	// sendtoken
 	if sendtoken.Parsed() {
-		if *fromAddr == "" || *sendSymbol == "" || *toAddr == "" || *tokenValue == 0 {
+		if *fromAddr == "" || *sendSymbol == "" || *toAddr == "" || *tokenValue == "" {
 			log.Fatal("sendtoken parames failed")
 		}
 
-		cli.SendToken(*fromAddr, *sendSymbol, *toAddr, *tokenValue)
+		opts, err := ParseTxOptions(0, "", "", *tokenSpeed, *tokenLegacy)
+		if err != nil {
+			log.Fatal("sendtoken parames failed: ", err)
+		}
+		opts.Force = *tokenForce
+
+		err = cli.SendToken(*fromAddr, *sendSymbol, *toAddr, *tokenValue, opts, *tokenYes, confirmationsFlag(*tokenWait, *tokenConfirmations))
+		if err == ErrRequestDenied {
+			log.Fatal("sendtoken cancelled")
+		}
+		if err != nil {
+			log.Fatal("failed to SendToken: ", err)
+		}
+	}
+
+	// audit
+	if audit.Parsed() {
+		if *auditName == "" {
+			log.Fatal("audit parames failed")
+		}
+
+		var pass []byte
+		if *auditDecrypt {
+			fmt.Println("Please input your password for decrypt keys")
+			var err error
+			pass, err = gopass.GetPasswd()
+			if err != nil {
+				log.Panic("failed to get your password:", err)
+			}
+		}
+
+		report, err := cli.Audit(*auditName, string(pass), *auditDecrypt)
+		if err != nil {
+			log.Fatal("failed to audit wallet: ", err)
+		}
+		PrintAudit(report, *auditJSON)
+		if report.Problems > 0 {
+			os.Exit(1)
+		}
+	}
+
+	// backup
+	if backup.Parsed() {
+		if *backupName == "" || *backupOut == "" {
+			log.Fatal("backup parames failed")
+		}
+
+		fmt.Println("Please input the password for encrypt the backup")
+		pass, err := gopass.GetPasswd()
+		if err != nil {
+			log.Panic("failed to get your password:", err)
+		}
+		fmt.Println("Please input the password again")
+		again, err := gopass.GetPasswd()
+		if err != nil {
+			log.Panic("failed to get your password:", err)
+		}
+		if string(pass) != string(again) {
+			log.Fatal("the passwords do not match")
+		}
+
+		if err := cli.Backup(*backupName, *backupOut, string(pass)); err != nil {
+			log.Fatal("failed to backup wallet: ", err)
+		}
+	}
+
+	// restore
+	if restore.Parsed() {
+		if *restoreIn == "" {
+			log.Fatal("restore parames failed")
+		}
+
+		fmt.Println("Please input the password of the backup")
+		pass, err := gopass.GetPasswd()
+		if err != nil {
+			log.Panic("failed to get your password:", err)
+		}
+
+		if err := cli.Restore(*restoreIn, *restoreName, string(pass), *restoreForce); err != nil {
+			log.Fatal("failed to restore wallet: ", err)
+		}
+	}
+
+	// signer
+	if signer.Parsed() {
+		if *signerName == "" && !*signerMnemonic {
+			log.Fatal("signer parames failed")
+		}
+
+		var pass, mnemonic []byte
+		var err error
+		if *signerName != "" {
+			fmt.Println("Please input your password for unlock the wallet")
+			pass, err = gopass.GetPasswd()
+			if err != nil {
+				log.Panic("failed to get your password:", err)
+			}
+		}
+		if *signerMnemonic {
+			fmt.Println("Please input the mnemonic of the hd wallet")
+			mnemonic, err = gopass.GetPasswd()
+			if err != nil {
+				log.Panic("failed to get your mnemonic:", err)
+			}
+		}
+
+		err = cli.RunSigner(*signerName, string(pass), string(mnemonic), *signerHD, *signerSocket, *signerHTTP, *signerRules)
+		if err != nil {
+			log.Fatal("signer stopped: ", err)
+		}
+	}
+
+	// agent
+	if agent.Parsed() {
+		if *agentName == "" {
+			log.Fatal("agent parames failed")
+		}
+
+		if err := cli.RunAgent(*agentName, *agentSocket); err != nil {
+			log.Fatal("agent stopped: ", err)
+		}
+	}
+
+	// unlock
+	if unlock.Parsed() {
+		if *unlockAddr == "" {
+			log.Fatal("unlock parames failed")
+		}
+
+		fmt.Println("Please input your password for unlock the account")
+		pass, err := gopass.GetPasswd()
+		if err != nil {
+			log.Panic("failed to get your password:", err)
+		}
+
+		if err := cli.AgentUnlock(*unlockAddr, string(pass), *unlockFor); err != nil {
+			log.Fatal("failed to unlock: ", err)
+		}
+		if *unlockFor > 0 {
+			log.Printf("%s unlocked for %v\n", *unlockAddr, *unlockFor)
+		} else {
+			log.Printf("%s unlocked until the agent exits\n", *unlockAddr)
+		}
+	}
+
+	// lock
+	if lock.Parsed() {
+		if err := cli.AgentLock(*lockAddr); err != nil {
+			log.Fatal("failed to lock: ", err)
+		}
+	}
+
+	// status
+	if status.Parsed() {
+		if err := cli.AgentStatus(); err != nil {
+			log.Fatal("failed to get agent status: ", err)
+		}
+	}
+
+	// migrate
+	if migrate.Parsed() {
+		if *migrateName == "" {
+			log.Fatal("migrate parames failed")
+		}
+
+		if err := cli.Migrate(*migrateName, *migrateTo, *migrateRemove); err != nil {
+			log.Fatal("failed to migrate: ", err)
+		}
+	}
+
+	// buildtx
+	if buildtx.Parsed() {
+		if *buildFrom == "" || *buildTo == "" || *buildValue == "" {
+			log.Fatal("buildtx parames failed")
+		}
+		opts, err := ParseTxOptions(*buildGas, *buildGasPrice, *buildData, *buildSpeed, *buildLegacy)
+		if err != nil {
+			log.Fatal("buildtx parames failed: ", err)
+		}
+		opts.Force = *buildForce
+
+		if err := cli.BuildTx(*buildFrom, *buildTo, *buildSymbol, *buildValue, opts, *buildOut); err != nil {
+			log.Fatal("failed to build the transaction: ", err)
+		}
+	}
+
+	// signtx
+	if signtx.Parsed() {
+		var mnemonic []byte
+		if *signMnemonic {
+			fmt.Println("Please input the mnemonic of the hd wallet")
+			var err error
+			mnemonic, err = gopass.GetPasswd()
+			if err != nil {
+				log.Panic("failed to get your mnemonic:", err)
+			}
+		}
+
+		if err := cli.SignOfflineTx(*signIn, *signOut, string(mnemonic), *signHD, *signYes); err != nil {
+			log.Fatal("failed to sign the transaction: ", err)
+		}
+	}
+
+	// broadcast
+	if broadcast.Parsed() {
+		if err := cli.Broadcast(*broadcastIn); err != nil {
+			log.Fatal("failed to broadcast the transaction: ", err)
+		}
+	}
+
+	// nonces
+	if nonces.Parsed() {
+		if !common.IsHexAddress(*noncesAddr) {
+			log.Fatal("nonces parames failed: -addr is not an address")
+		}
+		if *noncesRelease >= 0 {
+			if err := cli.ReleaseNonce(*noncesAddr, uint64(*noncesRelease)); err != nil {
+				log.Fatal("failed to release nonce: ", err)
+			}
+		} else if err := cli.ShowNonces(*noncesAddr); err != nil {
+			log.Fatal("failed to list nonces: ", err)
+		}
+	}
+
+	// speedup
+	if speedup.Parsed() {
+		if *speedupHash == "" {
+			log.Fatal("speedup parames failed: -hash is required")
+		}
+		opts, err := ParseTxOptions(0, *speedupGasPrice, "", *speedupSpeed, false)
+		if err != nil {
+			log.Fatal("speedup parames failed: ", err)
+		}
+		opts.Force = *speedupForce
+		if err := cli.ReplaceTx(*speedupHash, false, opts, *speedupYes); err != nil {
+			log.Fatal("failed to speed up the transaction: ", err)
+		}
+	}
+
+	// cancel
+	if cancel.Parsed() {
+		if *cancelHash == "" {
+			log.Fatal("cancel parames failed: -hash is required")
+		}
+		opts, err := ParseTxOptions(0, *cancelGasPrice, "", *cancelSpeed, false)
+		if err != nil {
+			log.Fatal("cancel parames failed: ", err)
+		}
+		if err := cli.ReplaceTx(*cancelHash, true, opts, *cancelYes); err != nil {
+			log.Fatal("failed to cancel the transaction: ", err)
+		}
+	}
+
+	// receipt
+	if receipt.Parsed() {
+		if *receiptHash == "" {
+			log.Fatal("receipt parames failed: -hash is required")
+		}
+		if err := cli.Receipt(*receiptHash); err != nil {
+			log.Fatal("failed to get the receipt: ", err)
+		}
+	}
+
+	// call
+	if call.Parsed() {
+		if !common.IsHexAddress(*callTo) || *callABI == "" || *callMethod == "" {
+			log.Fatal("call parames failed: -to, -abi and -method are required")
+		}
+		if err := cli.CallContract(*callFrom, *callTo, *callABI, *callMethod, *callArgs); err != nil {
+			log.Fatal("failed to call: ", err)
+		}
+	}
+
+	// send
+	if send.Parsed() {
+		if !common.IsHexAddress(*sendFrom) || !common.IsHexAddress(*sendTo) || *sendABI == "" || *sendMethod == "" {
+			log.Fatal("send parames failed: -from, -to, -abi and -method are required")
+		}
+		value, err := utils.ParseAmount(*sendValue)
+		if err != nil {
+			log.Fatal("send parames failed: invalid value ", *sendValue)
+		}
+		opts, err := ParseTxOptions(*sendGas, *sendGasPrice, "", *sendSpeed, *sendLegacy)
+		if err != nil {
+			log.Fatal("send parames failed: ", err)
+		}
+		opts.Force = *sendForce
+		if err := cli.SendContract(*sendFrom, *sendTo, *sendABI, *sendMethod, *sendArgs, value, opts, *sendYes, confirmationsFlag(*sendWait, *sendConfirmations)); err != nil {
+			log.Fatal("failed to send: ", err)
+		}
+	}
+
+	// batchpay
+	if batchpay.Parsed() {
+		if !common.IsHexAddress(*batchFrom) || *batchFile == "" {
+			log.Fatal("batchpay parames failed: -from and -file are required")
+		}
+		opts, err := ParseTxOptions(0, *batchGasPrice, "", *batchSpeed, *batchLegacy)
+		if err != nil {
+			log.Fatal("batchpay parames failed: ", err)
+		}
+		opts.Force = *batchForce
+		out := *batchOut
+		if out == "" {
+			out = strings.TrimSuffix(*batchFile, ".csv") + ".results.csv"
+		}
+		if err := cli.BatchPay(*batchFrom, *batchFile, *batchSymbol, out, opts, *batchYes, confirmationsFlag(*batchWait, *batchConfirmations)); err != nil {
+			log.Fatal("failed to batchpay: ", err)
+		}
+	}
+
+	// portfolio
+	if portfolio.Parsed() {
+		if *portfolioName == "" || (*portfolioJSON && *portfolioCSV) || *portfolioWorkers < 1 {
+			log.Fatal("portfolio parames failed")
+		}
+		var block *big.Int
+		if *portfolioBlock >= 0 {
+			block = big.NewInt(*portfolioBlock)
+		}
+		p, err := cli.GetPortfolio(*portfolioName, block, *portfolioWorkers)
+		if err != nil {
+			log.Fatal("failed to get portfolio: ", err)
+		}
+		format := "table"
+		if *portfolioJSON {
+			format = "json"
+		} else if *portfolioCSV {
+			format = "csv"
+		}
+		if err := PrintPortfolio(p, format); err != nil {
+			log.Fatal("failed to print portfolio: ", err)
+		}
+		if len(p.Errors) > 0 {
+			os.Exit(1)
+		}
+	}
+
+	// approve
+	if approve.Parsed() {
+		if !common.IsHexAddress(*approveFrom) || !common.IsHexAddress(*approveSpender) || *approveSymbol == "" || *approveValue == "" {
+			log.Fatal("approve parames failed: -from, -symbol, -spender and -value are required")
+		}
+		opts, err := ParseTxOptions(0, "", "", *approveSpeed, *approveLegacy)
+		if err != nil {
+			log.Fatal("approve parames failed: ", err)
+		}
+		opts.Force = *approveForce
+		if err := cli.Approve(*approveFrom, *approveSymbol, *approveSpender, *approveValue, opts, *approveYes, confirmationsFlag(*approveWait, 0)); err != nil {
+			log.Fatal("failed to approve: ", err)
+		}
+	}
+
+	// revoke
+	if revoke.Parsed() {
+		if !common.IsHexAddress(*revokeFrom) || !common.IsHexAddress(*revokeSpender) || *revokeSymbol == "" {
+			log.Fatal("revoke parames failed: -from, -symbol and -spender are required")
+		}
+		opts, err := ParseTxOptions(0, "", "", *revokeSpeed, *revokeLegacy)
+		if err != nil {
+			log.Fatal("revoke parames failed: ", err)
+		}
+		opts.Force = *revokeForce
+		if err := cli.Revoke(*revokeFrom, *revokeSymbol, *revokeSpender, opts, *revokeYes, confirmationsFlag(*revokeWait, 0)); err != nil {
+			log.Fatal("failed to revoke: ", err)
+		}
+	}
+
+	// allowance
+	if allowance.Parsed() {
+		if !common.IsHexAddress(*allowanceOwner) || !common.IsHexAddress(*allowanceSpender) || *allowanceSymbol == "" {
+			log.Fatal("allowance parames failed: -owner, -spender and -symbol are required")
+		}
+		if err := cli.Allowance(*allowanceOwner, *allowanceSpender, *allowanceSymbol); err != nil {
+			log.Fatal("failed to get allowance: ", err)
+		}
+	}
+
+	// transferfrom
+	if transferfrom.Parsed() {
+		if !common.IsHexAddress(*transferfromFrom) || !common.IsHexAddress(*transferfromOwner) || !common.IsHexAddress(*transferfromTo) || *transferfromSymbol == "" || *transferfromValue == "" {
+			log.Fatal("transferfrom parames failed: -from, -owner, -to, -symbol and -value are required")
+		}
+		opts, err := ParseTxOptions(0, "", "", *transferfromSpeed, *transferfromLegacy)
+		if err != nil {
+			log.Fatal("transferfrom parames failed: ", err)
+		}
+		opts.Force = *transferfromForce
+		if err := cli.TransferFrom(*transferfromFrom, *transferfromOwner, *transferfromTo, *transferfromSymbol, *transferfromValue, opts, *transferfromYes, confirmationsFlag(*transferfromWait, 0)); err != nil {
+			log.Fatal("failed to transferfrom: ", err)
+		}
+	}
+
+	// allowances
+	if allowances.Parsed() {
+		if *allowancesName == "" {
+			log.Fatal("allowances parames failed: -name is required")
+		}
+		approvals, err := cli.GetAllowances(*allowancesName, *allowancesFrom)
+		if err != nil {
+			log.Fatal("failed to get allowances: ", err)
+		}
+		if err := PrintAllowances(approvals, *allowancesJSON); err != nil {
+			log.Fatal("failed to print allowances: ", err)
+		}
+	}
+
+	// watch
+	if watch.Parsed() {
+		if *watchName == "" {
+			log.Fatal("watch parames failed: -name is required")
+		}
+		if err := cli.Watch(*watchName, *watchFrom, *watchConfirmations); err != nil {
+			log.Fatal("failed to watch: ", err)
+		}
+	}
+
+	// networks
+	if networks.Parsed() {
+		if err := cli.ShowNetworks(); err != nil {
+			log.Fatal("failed to list networks: ", err)
+		}
+	}
+
+	// endpoints
+	if endpoints.Parsed() {
+		if err := cli.ShowEndpoints(); err != nil {
+			log.Fatal("failed to check endpoints: ", err)
+		}
 	}
 }
 
@@ -237,7 +1244,7 @@ func (cli *CLI) CreateWallet(name, pass string) {
 	}
 
 	for i := 0; i < 10; i++ {
-		path := hdwallet.MustParseDerivationPath("m/44'/60'/0'/0/" + strconv.Itoa(i))
+		path := accountPath(cli.Network.CoinType, i)
 		// "m/44'/60'/0'/0/0" -> common.Address
 		account, err := wallet.Derive(path, true)
 		if err != nil {
@@ -261,12 +1268,17 @@ func (cli *CLI) CreateWallet(name, pass string) {
 }
 
 // GetBalance ...
-func (cli *CLI) GetBalance(account string) {
+func (cli *CLI) GetBalance(account string, wei bool) {
 	rclient, err := cli.GetAccount(account)
 	if err != nil {
 		log.Fatal("failed get account file")
 	}
-	fmt.Printf("The balance of %s is %v\n", account, cli.getBalance(account, rclient))
+	balance := cli.getBalance(account, rclient)
+	if wei {
+		fmt.Printf("The balance of %s is %s ether (%s wei)\n", account, utils.FormatEther(balance), balance)
+		return
+	}
+	fmt.Printf("The balance of %s is %s ether\n", account, utils.FormatEther(balance))
 }
 
 func (cli *CLI) getBalance(address string, client *rpc.Client) *big.Int {
@@ -278,255 +1290,293 @@ func (cli *CLI) getBalance(address string, client *rpc.Client) *big.Int {
 	return utils.Hex2bigInt(result)
 }
 
-// Transfer auth, Key
-func (cli *CLI) Transfer(from, to string, value int64) {
-	fileName, _, _, account, err := cli.getAccountKey(from)
-	client, err := ethclient.Dial(cli.NetworkURL)
+// Transfer auth, Key, waits for confirmations blocks when not 0
+func (cli *CLI) Transfer(from, to string, value *big.Int, opts TxOptions, yes bool, confirmations uint64) {
+	rclient, err := cli.rpcClient()
 	if err != nil {
 		log.Panic("failed to Transfer when Dial ", err)
 	}
-	defer client.Close()
-	// 获取当前nonce值
-	nonce, err := client.NonceAt(context.Background(), common.HexToAddress(account), nil)
-	if err != nil {
-		log.Panic("failed to Transfer when NonceAt ", err)
-	}
-	fmt.Println(nonce)
+	client := ethclient.NewClient(rclient)
 
-	gasLimit := uint64(30000)
-	gasPrice := big.NewInt(1000000)
-	tx := types.NewTransaction(nonce, common.HexToAddress(to), big.NewInt(value), gasLimit, gasPrice, []byte("salary"))
-
-	hdks := hdkeystore.NewHDKeyStore(cli.DataPath, nil)
-
-	fmt.Println("Please input your password for transfer")
-	auth, err := gopass.GetPasswd()
-	log.Println("your from address filename: ", fileName)
-	_, err = hdks.GetKey(common.HexToAddress(account), fileName, string(auth))
-	if err != nil {
-		log.Panic("failed to Transfer when GetKey ", err)
+	toAddr := common.HexToAddress(to)
+	msg := ethereum.CallMsg{From: common.HexToAddress(from), To: &toAddr, Value: value, Data: opts.Data}
+	stx, err := cli.sendMsg(context.Background(), rclient, msg, opts, yes, "")
+	if err == ErrRequestDenied {
+		log.Fatal("transfer cancelled")
 	}
-
-	stx, err := hdks.SignTx(common.HexToAddress(from), tx, nil)
 	if err != nil {
-		log.Panic("failed to Transfer when SignTx ", err)
+		log.Fatal("failed to Transfer: ", err)
 	}
 
-	err = client.SendTransaction(context.Background(), stx)
-	if err != nil {
-		log.Panic("failed to Transfer when SendTransaction ", err)
+	log.Printf("from: %s Transfer to: %s value: %s ether sent: %s\n", from, to, utils.FormatEther(value), stx.Hash().Hex())
+	cli.logExplorer(stx.Hash())
+	if confirmations > 0 {
+		if err := cli.waitAndReport(context.Background(), client, stx, confirmations); err != nil {
+			log.Fatal("failed to Transfer: ", err)
+		}
 	}
-
-	log.Printf("from: %s Transfer to: %s value: %d success\n", from, to, value)
 }
 
-// Addtoken ...
-func (cli *CLI) Addtoken(contactAddr string) (err error) {
-	data, tokens, err := cli.addtokenjson(contactAddr)
+// sendMsg signs and sends msg with the gas and fees of opts and the next
+// nonce of its sender, after the user confirmed the summary of the
+// transaction followed by extra, e.g. the decoded calldata
+func (cli *CLI) sendMsg(ctx context.Context, rclient *rpc.Client, msg ethereum.CallMsg, opts TxOptions, yes bool, extra string) (*types.Transaction, error) {
+	client := ethclient.NewClient(rclient)
+	if err := preflight(ctx, rclient, msg, opts.Force); err != nil {
+		return nil, err
+	}
+	// the token balance too for a call of an ERC-20 method
+	var token *common.Address
+	if len(msg.Data) >= 4 {
+		if _, err := erc20ABI.MethodById(msg.Data[:4]); err == nil {
+			token = msg.To
+		}
+	}
+	if err := cli.checkQuorum(ctx, msg.From, token); err != nil {
+		return nil, err
+	}
+	id, err := cli.chainID(ctx, rclient)
 	if err != nil {
-		log.Println("failed to cli.addtokenjson", err)
-		return err
+		return nil, err
 	}
-
-	symbol, err := cli.GetSymbol(contactAddr)
+	if id == nil {
+		opts.Legacy = true
+	}
+	if err := fillGas(ctx, rclient, msg, &opts); err != nil {
+		return nil, err
+	}
+	// the summary and the funds don't depend on the nonce, it is only
+	// reserved once the user confirmed and entered the password
+	tx := newTx(0, msg, opts, id)
+	if err := checkFunds(ctx, rclient, msg.From, tx); err != nil {
+		return nil, err
+	}
+	if !yes && !confirm("Send?", transferSummary(msg.From.Hex(), tx)+extra) {
+		return nil, ErrRequestDenied
+	}
+	sign, err := cli.txSigner(msg.From.Hex())
 	if err != nil {
-		log.Println("failed to cli.GetSymbol", err)
-		return err
+		return nil, err
 	}
 
-	newtoken := TokenConfig{symbol, contactAddr}
-	tokens = append(tokens, newtoken)
-	data, _ = json.Marshal(tokens)
-	utils.WriteKeyFile("tokens.json", data)
-	log.Println("add token successfully")
-	return err
-}
-
-func (cli *CLI) addtokenjson(contactAddr string) (data []byte, tokens []TokenConfig, err error) {
-	tokens = []TokenConfig{}
-
-	data, err = ioutil.ReadFile(cli.TokensFile)
+	// 获取下一个nonce值, 发送前其他进程不会使用
+	nonce, err := cli.reserveNonce(ctx, client, msg.From, false)
 	if err != nil {
-		log.Fatal("failed to ioutil.ReadFile")
+		return nil, err
 	}
-	err = json.Unmarshal(data, &tokens)
+	stx, err := sign(newTx(nonce, msg, opts, id), id)
 	if err != nil {
-		log.Fatal("failed to json.Unmarshal")
+		cli.releaseNonce(msg.From, nonce)
+		return nil, err
 	}
-	fmt.Println(tokens)
-
-	if ok, _ := checkUniqueByAddr(contactAddr, tokens); !ok {
-		log.Fatal("failed to checkUnique ")
+	if err := client.SendTransaction(ctx, stx); err != nil {
+		cli.releaseNonce(msg.From, nonce)
+		return nil, err
 	}
-	return data, tokens, nil
+	cli.recordSent(msg.From, stx)
+	return stx, nil
 }
 
-func checkUniqueByAddr(address string, tokens []TokenConfig) (bool, error) {
-	for _, token := range tokens {
-		if token.Addr == address {
-			return false, fmt.Errorf("token: %s existed", token.Symbol)
-		}
+// transferSummary describes an ether transfer in ether for confirmation
+func transferSummary(from string, tx *types.Transaction) string {
+	maxFee := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	gas := fmt.Sprintf("%d at %s gwei", tx.Gas(), utils.FormatUnits(tx.GasPrice(), 9))
+	if tx.Type() == types.DynamicFeeTxType {
+		gas = fmt.Sprintf("%d at max %s gwei, tip %s gwei", tx.Gas(), utils.FormatUnits(tx.GasFeeCap(), 9), utils.FormatUnits(tx.GasTipCap(), 9))
 	}
-	return true, nil
+	to := "contract creation"
+	if tx.To() != nil {
+		to = tx.To().Hex()
+	}
+	return fmt.Sprintf("from:      %s\nto:        %s\nvalue:     %s ether (%s wei)\ngas:       %s\nmax fee:   %s ether\ntotal:     %s ether",
+		common.HexToAddress(from).Hex(), to, utils.FormatEther(tx.Value()), tx.Value(),
+		gas, utils.FormatEther(maxFee), utils.FormatEther(tx.Cost()))
 }
 
-func checkUniqueBySymbol(symbol string, tokens []TokenConfig) (bool, error) {
-	for _, token := range tokens {
-		if token.Symbol == symbol {
-			return false, fmt.Errorf("token: %s existed", token.Symbol)
-		}
+// confirm prints summary and asks question, e.g. "Send?", to the user
+func confirm(question, summary string) bool {
+	fmt.Printf("\n%s\n%s [y/N] ", summary, question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
 	}
-	return true, nil
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-// GetSymbol ...
-func (cli *CLI) GetSymbol(address string) (string, error) {
-	client, err := ethclient.Dial(cli.NetworkURL)
-	if err != nil {
-		log.Panic("failed to GetSymbol when Dial:", err)
+// remoteSigner returns the agent socket if the agent holds from unlocked,
+// otherwise the signer daemon url, empty if keys are used locally
+func (cli *CLI) remoteSigner(from common.Address) string {
+	if socket, ok := agentUnlocked(from); ok {
+		return socket
 	}
-	defer client.Close()
-	pxc, err := abi.NewPxc(common.HexToAddress(address), client)
+	return cli.SignerURL
+}
+
+// signTx signs tx for chainID with the agent or signer daemon if available,
+// otherwise with the keystore file of from
+func (cli *CLI) signTx(from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	sign, err := cli.txSigner(from)
 	if err != nil {
-		log.Panic("failed to abi.NewPxc:", err)
+		return nil, err
 	}
-	return pxc.Symbol(nil)
+	return sign(tx, chainID)
 }
 
-// TokenBalance ...
-func (cli *CLI) TokenBalance(addr, symbol string) (int64, error) {
-	tokenAddr, err := cli.getSymbolAddr(symbol)
-	if err != nil {
-		log.Panicln("failed to cli.getSymbolAddr: ", err)
+// txSigner returns the signer of the transactions of from, the password of
+// a local key is asked here so that the caller can prompt before signing
+func (cli *CLI) txSigner(from string) (func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error), error) {
+	addr := common.HexToAddress(from)
+	if url := cli.remoteSigner(addr); url != "" {
+		return func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+			return remoteSignTx(url, addr, tx, chainID)
+		}, nil
 	}
-	client, err := ethclient.Dial(cli.NetworkURL)
+
+	fileName, key, _, err := cli.getAccountKey(from)
 	if err != nil {
-		log.Panic("failed to GetSymbol when Dial:", err)
+		return nil, err
 	}
-	defer client.Close()
-	pxc, err := abi.NewPxc(common.HexToAddress(tokenAddr), client)
+	log.Println("your from address filename: ", fileName)
+
+	hdks := hdkeystore.NewHDKeyStore(cli.DataPath, key.PrivateKey)
+	return func(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+		return hdks.SignTx(addr, tx, chainID)
+	}, nil
+}
+
+// Addtoken adds the token contract at contactAddr to the token registry of
+// the network, under alias when another token has the same symbol. With
+// selectors the bytecode must dispatch the ERC-20 functions, with force a
+// contract that doesn't look like a token is added anyway.
+func (cli *CLI) Addtoken(contactAddr, alias string, selectors, force bool) error {
+	token, err := cli.registerToken(contactAddr, alias, selectors, force)
 	if err != nil {
-		log.Panic("failed to abi.NewPxc:", err)
+		return err
 	}
-	balance, err := pxc.BalanceOf(nil, common.HexToAddress(addr))
-	if err != nil {
-		log.Panic("failed to pxc.BalanceOf:", err)
+	if token.Name != "" {
+		log.Println("token name: ", token.Name)
 	}
-	fmt.Printf("your symbol: %s balance is: %d \n", symbol, balance.Int64())
-	return balance.Int64(), nil
+	log.Printf("add token %s (%d decimals) %s to %s successfully\n", token.Key(), *token.Decimals, token.Addr, cli.TokensFile)
+	return nil
 }
 
-// SendToken ...
-func (cli *CLI) SendToken(from, symbol, to string, value int64) {
-	tokenAddr, err := cli.getSymbolAddr(symbol)
+// GetSymbol ...
+func (cli *CLI) GetSymbol(address string) (string, error) {
+	client, err := cli.ethClient()
 	if err != nil {
-		log.Panicln("failed to cli.ggetSymbolAddr: ", err)
+		log.Panic("failed to GetSymbol when Dial:", err)
 	}
-	log.Println("from address: ", from)
-	fileName, _, _, _, err := cli.getAccountKey(from)
+	return tokenString(context.Background(), client, common.HexToAddress(address), "symbol")
+}
 
-	fmt.Println("get your filename: ", fileName)
-	opt, err := cli.makeAuth(from, fileName)
+// TokenBalance prints the balance of addr of the token symbol in token units
+func (cli *CLI) TokenBalance(addr, symbol string, raw bool) (*big.Int, error) {
+	token, err := cli.lookupToken(context.Background(), symbol)
 	if err != nil {
-		log.Panicln("failed to cli.makeAuth: ", err)
+		log.Panicln("failed to cli.lookupToken: ", err)
 	}
-
-	pxc, err := cli.getContact(tokenAddr)
+	client, err := cli.ethClient()
 	if err != nil {
-		log.Panicln("failed to cli.getContact: ", err)
+		log.Panic("failed to TokenBalance when Dial:", err)
 	}
-
-	txhash, err := pxc.Transfer(opt, common.HexToAddress(to), big.NewInt(value))
+	balance, err := tokenBalance(context.Background(), client, token.Address, common.HexToAddress(addr))
 	if err != nil {
-		log.Panic("failed to Transfer ", err)
+		log.Panic("failed to BalanceOf:", err)
 	}
-	fmt.Println("sendtoken call ok,hash=", txhash.Hash().Hex())
+	if raw {
+		fmt.Printf("your symbol: %s balance is: %s (%s base units)\n", symbol, token.Format(balance), balance)
+		return balance, nil
+	}
+	fmt.Printf("your symbol: %s balance is: %s \n", symbol, token.Format(balance))
+	return balance, nil
 }
 
-func (cli *CLI) getSymbolAddr(symbol string) (string, error) {
-	tokens := []TokenConfig{}
-	data, err := ioutil.ReadFile(cli.TokensFile)
+// SendToken sends amount in units of the token symbol, e.g. 12.5, after
+// confirmation, waits for confirmations blocks when not 0
+func (cli *CLI) SendToken(from, symbol, to, amount string, opts TxOptions, yes bool, confirmations uint64) error {
+	ctx := context.Background()
+	token, err := cli.lookupToken(ctx, symbol)
 	if err != nil {
-		log.Fatal("failed to ioutil.ReadFile")
+		return err
 	}
-	err = json.Unmarshal(data, &tokens)
+	value, err := token.Parse(amount)
 	if err != nil {
-		log.Fatal("failed to json.Unmarshal")
-	}
-
-	fmt.Println("symbol: ", symbol)
-	if ok, _ := checkUniqueBySymbol(symbol, tokens); ok {
-		log.Fatal("the token symbol is not exist ")
+		return err
 	}
-
-	var tokenAddr string
-	for _, v := range tokens {
-		if symbol == v.Symbol {
-			tokenAddr = v.Addr
-		}
+	if value.Sign() == 0 {
+		return fmt.Errorf("invalid value %s", amount)
 	}
-	return tokenAddr, nil
-}
-
-func (cli *CLI) makeAuth(from, fileName string) (opt *bind.TransactOpts, err error) {
-	hdks := hdkeystore.NewHDKeyStore(cli.DataPath, nil)
-	fmt.Println("Please input your password for transfer")
-	auth, err := gopass.GetPasswd()
-	_, err = hdks.GetKey(common.HexToAddress(from), fileName, string(auth))
+	data, err := erc20ABI.Pack("transfer", common.HexToAddress(to), value)
 	if err != nil {
-		log.Panic("failed to Transfer when GetKey ", err)
+		return err
 	}
-	keyin, err := os.Open(fileName)
+	rclient, err := cli.rpcClient()
 	if err != nil {
-		log.Panic("failed to read keystore file ", err)
+		return err
 	}
-	opt, err = bind.NewTransactor(keyin, string(auth))
+
+	msg := ethereum.CallMsg{From: common.HexToAddress(from), To: &token.Address, Data: data}
+	tx, err := cli.sendMsg(ctx, rclient, msg, opts, yes, erc20CallSummary(token.Address, data, token.Symbol, &token.Decimals))
 	if err != nil {
-		log.Panic("failed to bind.NewTransactor ", err)
+		return err
 	}
-	return
+	fmt.Println("sendtoken call ok,hash=", tx.Hash().Hex())
+	cli.logExplorer(tx.Hash())
+	if confirmations > 0 {
+		return cli.waitAndReport(ctx, ethclient.NewClient(rclient), tx, confirmations)
+	}
+	return nil
 }
 
-func (cli *CLI) getContact(tokenAddr string) (pxc *abi.Pxc, err error) {
-	client, err := ethclient.Dial(cli.NetworkURL)
+func (cli *CLI) getContact(tokenAddr string) (erc20 *abi.ERC20, err error) {
+	client, err := cli.ethClient()
 	if err != nil {
 		log.Panic("failed to GetSymbol when Dial:", err)
 	}
-	defer client.Close()
-	pxc, err = abi.NewPxc(common.HexToAddress(tokenAddr), client)
+	erc20, err = abi.NewERC20(common.HexToAddress(tokenAddr), client)
 	return
 }
 
 // GetAccount ...
 func (cli *CLI) GetAccount(account string) (rclient *rpc.Client, err error) {
-	rclient, err = rpc.Dial(cli.NetworkURL)
+	rclient, err = cli.rpcClient()
 	if err != nil {
 		log.Fatal("failed to rpc.Dial ...")
 	}
 	return rclient, err
 }
 
-// getAccountKey ...
-func (cli *CLI) getAccountKey(account string) (fileName string, rclient *rpc.Client, key *keystore.Key, accountAddr string, err error) {
-	rclient, err = rpc.Dial(cli.NetworkURL)
-	if err != nil {
-		log.Panic("failed to Dial:", err)
-	}
-	defer rclient.Close()
-
+// getAccountKey asks for the wallet and password of account and decrypts
+// its key, it needs no network access
+func (cli *CLI) getAccountKey(account string) (fileName string, key *keystore.Key, accountAddr string, err error) {
 	fmt.Println("Please input your wallet")
 
 	var buffer string
 	for {
 		inputReader := bufio.NewReader(os.Stdin)
 		buffer, err = inputReader.ReadString('\n')
-		buffer = buffer[:len(buffer)-2]
+		buffer = strings.TrimSpace(buffer)
 		if err == nil && buffer != "" {
 			break
 		}
 		fmt.Println("Input walletDir err or nil.Please input agin")
 	}
 
+	// wallets migrated to the db storage have no key files
+	if _, err := os.Stat(cli.walletDB(buffer)); err == nil {
+		fmt.Println("Please input your password for get key")
+		auth, err := gopass.GetPasswd()
+		if err != nil {
+			return "", nil, "", err
+		}
+		key, err = cli.dbAccountKey(buffer, common.HexToAddress(account), string(auth))
+		if err != nil {
+			return "", nil, "", err
+		}
+		return cli.walletDB(buffer), key, account, nil
+	}
+
 	infos, err := ioutil.ReadDir(cli.DataPath + "/" + buffer)
 	if err != nil {
 		fmt.Println("failed to ReadDir:", err)
@@ -541,8 +1591,8 @@ func (cli *CLI) getAccountKey(account string) (fileName string, rclient *rpc.Cli
 		if err == nil {
 			accountAddr = account
 			fileName = utils.WalletDir(cli.DataPath+"/"+buffer, info.Name())
-			return fileName, rclient, key, accountAddr, nil
+			return fileName, key, accountAddr, nil
 		}
 	}
-	return "", nil, nil, "", fmt.Errorf("key content mismatch: have account%s", account)
+	return "", nil, "", fmt.Errorf("key content mismatch: have account%s", account)
 }
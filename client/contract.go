@@ -0,0 +1,327 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// LoadABI reads a contract abi from a json file: the abi array itself, or
+// a compiler artifact with an "abi" field (truffle, hardhat)
+func LoadABI(file string) (ethabi.ABI, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ethabi.ABI{}, err
+	}
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if json.Unmarshal(data, &artifact) == nil && len(artifact.ABI) > 0 {
+		data = artifact.ABI
+	}
+	parsed, err := ethabi.JSON(strings.NewReader(string(data)))
+	if err != nil {
+		return ethabi.ABI{}, fmt.Errorf("invalid abi file %s: %v", file, err)
+	}
+	return parsed, nil
+}
+
+// SplitArgs splits the -args list at top level commas: arrays are written
+// in brackets, e.g. 0xabc..,100,[1,2,3], and values with commas or
+// brackets are double quoted
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	if strings.TrimSpace(s) == "" {
+		return args, nil
+	}
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced ] in %q", s)
+			}
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, fmt.Errorf("unbalanced brackets or quotes in %q", s)
+	}
+	return append(args, strings.TrimSpace(s[start:])), nil
+}
+
+// parseArg converts s to the go value of the abi type t
+func parseArg(t ethabi.Type, s string) (interface{}, error) {
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s: %v", s, err)
+		}
+		s = unquoted
+	}
+	switch t.T {
+	case ethabi.AddressTy:
+		return parseAddress(s)
+	case ethabi.BoolTy:
+		return strconv.ParseBool(s)
+	case ethabi.StringTy:
+		return s, nil
+	case ethabi.BytesTy:
+		return hexutil.Decode(s)
+	case ethabi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != t.Size {
+			return nil, fmt.Errorf("%s needs %d bytes, got %d", t, t.Size, len(b))
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	case ethabi.IntTy, ethabi.UintTy:
+		return parseInt(t, s)
+	case ethabi.SliceTy, ethabi.ArrayTy:
+		if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("%s needs a list in brackets, got %q", t, s)
+		}
+		elems, err := SplitArgs(s[1 : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		var v reflect.Value
+		if t.T == ethabi.ArrayTy {
+			if len(elems) != t.Size {
+				return nil, fmt.Errorf("%s needs %d values, got %d", t, t.Size, len(elems))
+			}
+			v = reflect.New(t.GetType()).Elem()
+		} else {
+			v = reflect.MakeSlice(t.GetType(), len(elems), len(elems))
+		}
+		for i, elem := range elems {
+			e, err := parseArg(*t.Elem, elem)
+			if err != nil {
+				return nil, err
+			}
+			v.Index(i).Set(reflect.ValueOf(e))
+		}
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("arguments of type %s are not supported", t)
+}
+
+// parseInt parses a decimal or 0x hex integer, or an amount of ether with
+// a unit, e.g. 1.5ether, into the go integer type of t
+func parseInt(t ethabi.Type, s string) (interface{}, error) {
+	var n *big.Int
+	var ok bool
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "-0x"):
+		n, ok = new(big.Int).SetString(strings.Replace(s, "0x", "", 1), 16)
+	case strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyz") != s:
+		amount, err := utils.ParseAmount(s)
+		if err != nil {
+			return nil, err
+		}
+		n, ok = amount, true
+	default:
+		n, ok = new(big.Int).SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid %s %q", t, s)
+	}
+	// range of the abi type
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+	if t.T == ethabi.IntTy {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+		return nil, fmt.Errorf("%s out of range of %s", s, t)
+	}
+	goType := t.GetType()
+	if goType == reflect.TypeOf(n) {
+		return n, nil
+	}
+	// uint8..uint64 and int8..int64
+	if t.T == ethabi.UintTy {
+		return reflect.ValueOf(n.Uint64()).Convert(goType).Interface(), nil
+	}
+	return reflect.ValueOf(n.Int64()).Convert(goType).Interface(), nil
+}
+
+// packCall looks up method in the abi and encodes its call with the -args list
+func packCall(contract ethabi.ABI, method, args string) (ethabi.Method, []byte, error) {
+	m, ok := contract.Methods[method]
+	if !ok {
+		return m, nil, fmt.Errorf("method %s not found in the abi", method)
+	}
+	list, err := SplitArgs(args)
+	if err != nil {
+		return m, nil, err
+	}
+	if len(list) != len(m.Inputs) {
+		return m, nil, fmt.Errorf("%s needs %d arguments, got %d", m.Sig, len(m.Inputs), len(list))
+	}
+	values := make([]interface{}, len(list))
+	for i, input := range m.Inputs {
+		if values[i], err = parseArg(input.Type, list[i]); err != nil {
+			return m, nil, fmt.Errorf("argument %d %s: %v", i, input.Name, err)
+		}
+	}
+	data, err := contract.Pack(method, values...)
+	return m, data, err
+}
+
+// formatValue formats a decoded abi value: addresses and bytes in hex,
+// integers in decimal and lists in brackets
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case common.Address:
+		return x.Hex()
+	case []byte:
+		return hexutil.Encode(x)
+	case *big.Int:
+		return x.String()
+	case string:
+		return strconv.Quote(x)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		elems := make([]string, rv.Len())
+		for i := range elems {
+			elems[i] = formatValue(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(elems, ",") + "]"
+	case reflect.Struct:
+		fields := make([]string, rv.NumField())
+		for i := range fields {
+			fields[i] = formatValue(rv.Field(i).Interface())
+		}
+		return "(" + strings.Join(fields, ",") + ")"
+	}
+	return fmt.Sprint(v)
+}
+
+// callSummary describes the call of method with the calldata data
+func callSummary(m ethabi.Method, data []byte) string {
+	values, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return fmt.Sprintf("\nmethod:    %s", m.Sig)
+	}
+	summary := fmt.Sprintf("\nmethod:    %s", m.Sig)
+	for i, input := range m.Inputs {
+		summary += fmt.Sprintf("\n  %s %s = %s", input.Type, input.Name, formatValue(values[i]))
+	}
+	return summary
+}
+
+// CallContract calls the read only method of the contract to with eth_call
+// and prints its decoded outputs. from sets msg.sender if not empty.
+func (cli *CLI) CallContract(from, to, abiFile, method, args string) error {
+	contract, err := LoadABI(abiFile)
+	if err != nil {
+		return err
+	}
+	m, data, err := packCall(contract, method, args)
+	if err != nil {
+		return err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+
+	toAddr := common.HexToAddress(to)
+	msg := ethereum.CallMsg{To: &toAddr, Data: data}
+	if from != "" {
+		msg.From = common.HexToAddress(from)
+	}
+	out, err := client.CallContract(context.Background(), msg, nil)
+	if err != nil {
+		return err
+	}
+	if len(out) == 0 && len(m.Outputs) > 0 {
+		return fmt.Errorf("empty result, is %s a contract with %s?", toAddr.Hex(), m.Sig)
+	}
+	values, err := m.Outputs.Unpack(out)
+	if err != nil {
+		return fmt.Errorf("failed to decode the result %s: %v", hexutil.Encode(out), err)
+	}
+	for i, output := range m.Outputs {
+		name := output.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		fmt.Printf("%s (%s): %s\n", name, output.Type, formatValue(values[i]))
+	}
+	return nil
+}
+
+// SendContract signs and sends a call of method of the contract to from
+// the account from, with value wei for payable methods
+func (cli *CLI) SendContract(from, to, abiFile, method, args string, value *big.Int, opts TxOptions, yes bool, confirmations uint64) error {
+	contract, err := LoadABI(abiFile)
+	if err != nil {
+		return err
+	}
+	m, data, err := packCall(contract, method, args)
+	if err != nil {
+		return err
+	}
+	if m.IsConstant() {
+		return fmt.Errorf("%s is %s, read it with call", m.Sig, m.StateMutability)
+	}
+	if value.Sign() > 0 && !m.IsPayable() {
+		return fmt.Errorf("%s is not payable, it can't receive value", m.Sig)
+	}
+	rclient, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	toAddr := common.HexToAddress(to)
+	msg := ethereum.CallMsg{From: common.HexToAddress(from), To: &toAddr, Value: value, Data: data}
+	tx, err := cli.sendMsg(ctx, rclient, msg, opts, yes, callSummary(m, data))
+	if err != nil {
+		return err
+	}
+	log.Printf("from: %s call %s on %s sent: %s\n", msg.From.Hex(), m.Sig, toAddr.Hex(), tx.Hash().Hex())
+	cli.logExplorer(tx.Hash())
+	if confirmations > 0 {
+		return cli.waitAndReport(ctx, ethclient.NewClient(rclient), tx, confirmations)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultMaxLag is how many blocks an endpoint may be behind the highest one
+const defaultMaxLag = 5
+
+// Endpoint is a node of the network and the result of its health check
+type Endpoint struct {
+	URL     string
+	ChainID uint64
+	Head    uint64
+	Latency time.Duration
+	// Err is why the endpoint is not used: unreachable, other chain or lagging
+	Err error
+
+	transport string
+	rc        *rpc.Client
+}
+
+// Healthy reports whether the endpoint answered on the right chain and is
+// not lagging
+func (e *Endpoint) Healthy() bool {
+	return e.Err == nil
+}
+
+// probeEndpoint connects to the node url and asks its chain id and block
+// height in one batch, the latency is the time of that batch
+func probeEndpoint(ctx context.Context, rawurl string, chainID uint64) *Endpoint {
+	e := &Endpoint{URL: rawurl}
+	if e.transport, e.Err = nodeTransport(rawurl); e.Err != nil {
+		return e
+	}
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	if e.rc, e.Err = rpc.DialContext(ctx, rawurl); e.Err != nil {
+		return e
+	}
+	var id, head hexutil.Uint64
+	batch := []rpc.BatchElem{
+		{Method: "eth_chainId", Result: &id},
+		{Method: "eth_blockNumber", Result: &head},
+	}
+	start := time.Now()
+	e.Err = e.rc.BatchCallContext(ctx, batch)
+	e.Latency = time.Since(start)
+	for _, elem := range batch {
+		if e.Err == nil {
+			e.Err = elem.Error
+		}
+	}
+	if e.Err != nil {
+		return e
+	}
+	e.ChainID, e.Head = uint64(id), uint64(head)
+	if chainID != 0 && e.ChainID != chainID {
+		e.Err = fmt.Errorf("on chain %d, expected %d", e.ChainID, chainID)
+	}
+	return e
+}
+
+// checkEndpoints probes the endpoints of the network concurrently and marks
+// the ones more than maxLag blocks behind the highest as lagging. The
+// healthy endpoints come first, fastest first.
+func checkEndpoints(ctx context.Context, urls []string, chainID, maxLag uint64) []*Endpoint {
+	endpoints := make([]*Endpoint, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			endpoints[i] = probeEndpoint(ctx, u, chainID)
+		}(i, u)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for _, e := range endpoints {
+		if e.Healthy() && e.Head > highest {
+			highest = e.Head
+		}
+	}
+	for _, e := range endpoints {
+		if e.Healthy() && e.Head+maxLag < highest {
+			e.Err = fmt.Errorf("lagging %d blocks behind", highest-e.Head)
+		}
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].Healthy() != endpoints[j].Healthy() {
+			return endpoints[i].Healthy()
+		}
+		return endpoints[i].Healthy() && endpoints[i].Latency < endpoints[j].Latency
+	})
+	return endpoints
+}
+
+// connectEndpoints checks the endpoints of the network and connects to the
+// healthy ones: http endpoints through a failover transport that retries
+// reads on the next endpoint. Only the http transport fails over, the
+// fastest websocket or ipc node is used only when fewer than two http
+// endpoints are healthy.
+func (cli *CLI) connectEndpoints() (*rpc.Client, string, error) {
+	cli.endpoints = checkEndpoints(context.Background(), cli.Network.Endpoints, cli.Network.ChainID, cli.Network.MaxLag)
+	var healthy []*Endpoint
+	for _, e := range cli.endpoints {
+		if e.Healthy() {
+			healthy = append(healthy, e)
+		} else {
+			log.Printf("endpoint %s not used: %v\n", e.URL, e.Err)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, "", fmt.Errorf("no healthy endpoint of network %s", cli.Network.Name)
+	}
+	var urls []string
+	var best *Endpoint
+	for _, e := range healthy {
+		if e.transport == TransportHTTP {
+			if best == nil {
+				best = e
+			}
+			urls = append(urls, e.URL)
+		}
+	}
+	if len(urls) < 2 && healthy[0].transport != TransportHTTP {
+		best = healthy[0]
+		if len(healthy) > 1 {
+			log.Printf("endpoint %s has no failover, the %s transport can't switch to another node\n", best.URL, best.transport)
+		}
+		log.Printf("using endpoint %s (block %d, %v)\n", best.URL, best.Head, best.Latency.Round(time.Microsecond))
+		return best.rc, best.transport, nil
+	}
+	ft, err := newFailoverTransport(urls)
+	if err != nil {
+		return nil, "", err
+	}
+	rc, err := rpc.DialHTTPWithClient(best.URL, &http.Client{Transport: ft})
+	if err != nil {
+		return nil, "", err
+	}
+	log.Printf("using endpoint %s (block %d, %v), %d more for failover\n", best.URL, best.Head, best.Latency.Round(time.Microsecond), len(urls)-1)
+	return rc, TransportHTTP, nil
+}
+
+// failoverTransport sends the json-rpc requests of an http rpc.Client to
+// the current endpoint and moves to the next one when it fails. Requests
+// sending transactions are only retried if they were not delivered.
+type failoverTransport struct {
+	urls []*url.URL
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	current int
+}
+
+func newFailoverTransport(urls []string) (*failoverTransport, error) {
+	ft := &failoverTransport{base: http.DefaultTransport}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		ft.urls = append(ft.urls, parsed)
+	}
+	return ft, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (ft *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	write := bytes.Contains(body, []byte(`"eth_sendRawTransaction"`)) || bytes.Contains(body, []byte(`"eth_sendTransaction"`))
+
+	ft.mu.Lock()
+	start := ft.current
+	ft.mu.Unlock()
+	var lastErr error
+	for i := range ft.urls {
+		idx := (start + i) % len(ft.urls)
+		r := req.Clone(req.Context())
+		r.URL, r.Host = ft.urls[idx], ""
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+
+		resp, err := ft.base.RoundTrip(r)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			ft.mu.Lock()
+			ft.current = idx
+			ft.mu.Unlock()
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		lastErr = fmt.Errorf("endpoint %s: %v", ft.urls[idx].Redacted(), err)
+		if req.Context().Err() != nil || (write && !notDelivered(err)) {
+			break
+		}
+		if i+1 < len(ft.urls) {
+			log.Printf("%v, trying %s\n", lastErr, ft.urls[(idx+1)%len(ft.urls)].Redacted())
+		}
+	}
+	return nil, lastErr
+}
+
+// notDelivered reports whether the request failed before reaching the node
+func notDelivered(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// quorumRead is a value compared between the endpoints in quorum mode,
+// read at the block appended to args
+type quorumRead struct {
+	what   string
+	method string
+	args   []interface{}
+}
+
+// accountReads are the balance and nonce of account, and its balance of
+// token if not nil
+func accountReads(account common.Address, token *common.Address) []quorumRead {
+	reads := []quorumRead{
+		{"balance", "eth_getBalance", []interface{}{account}},
+		{"nonce", "eth_getTransactionCount", []interface{}{account}},
+	}
+	if token != nil {
+		data, _ := erc20ABI.Pack("balanceOf", account)
+		call := map[string]interface{}{"to": token, "data": hexutil.Bytes(data)}
+		reads = append(reads, quorumRead{"token balance", "eth_call", []interface{}{call}})
+	}
+	return reads
+}
+
+// checkQuorum compares the balance and nonce of account, and its token
+// balance, between the healthy endpoints before a transaction based on
+// them is signed. At least Quorum endpoints must answer and all answers
+// must be equal; values are read at the lowest head of the endpoints so
+// that nodes a few blocks apart still agree.
+func (cli *CLI) checkQuorum(ctx context.Context, account common.Address, token *common.Address) error {
+	quorum := cli.Network.Quorum
+	if quorum <= 1 {
+		return nil
+	}
+	if _, err := cli.rpcClient(); err != nil {
+		return err
+	}
+	var healthy []*Endpoint
+	var block uint64
+	for _, e := range cli.endpoints {
+		if e.Healthy() {
+			if len(healthy) == 0 || e.Head < block {
+				block = e.Head
+			}
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) < quorum {
+		return fmt.Errorf("quorum %d: only %d healthy endpoints", quorum, len(healthy))
+	}
+
+	reads := accountReads(account, token)
+	answers := make([][]string, len(healthy))
+	errs := make([]error, len(healthy))
+	var wg sync.WaitGroup
+	for i, e := range healthy {
+		wg.Add(1)
+		go func(i int, e *Endpoint) {
+			defer wg.Done()
+			batch := make([]rpc.BatchElem, len(reads))
+			results := make([]string, len(reads))
+			for j, r := range reads {
+				args := append(append([]interface{}{}, r.args...), hexutil.EncodeUint64(block))
+				batch[j] = rpc.BatchElem{Method: r.method, Args: args, Result: &results[j]}
+			}
+			if errs[i] = e.rc.BatchCallContext(ctx, batch); errs[i] == nil {
+				for _, elem := range batch {
+					if elem.Error != nil {
+						errs[i] = elem.Error
+						break
+					}
+				}
+			}
+			answers[i] = results
+		}(i, e)
+	}
+	wg.Wait()
+
+	var answered []int
+	for i := range healthy {
+		if errs[i] != nil {
+			log.Printf("quorum: endpoint %s failed: %v\n", healthy[i].URL, errs[i])
+			continue
+		}
+		answered = append(answered, i)
+	}
+	if len(answered) < quorum {
+		return fmt.Errorf("quorum %d: only %d endpoints answered for %s", quorum, len(answered), account.Hex())
+	}
+	for j, r := range reads {
+		first := answers[answered[0]][j]
+		for _, i := range answered[1:] {
+			if !sameQuantity(answers[i][j], first) {
+				var values []string
+				for _, k := range answered {
+					values = append(values, fmt.Sprintf("%s = %s", healthy[k].URL, answers[k][j]))
+				}
+				return fmt.Errorf("endpoints disagree on the %s of %s at block %d: %s", r.what, account.Hex(), block, strings.Join(values, ", "))
+			}
+		}
+	}
+	log.Printf("quorum: %d endpoints agree on the account %s at block %d\n", len(answered), account.Hex(), block)
+	return nil
+}
+
+// sameQuantity compares two hex answers, ignoring leading zeros
+func sameQuantity(a, b string) bool {
+	x, okx := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(a), "0x"), 16)
+	y, oky := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(b), "0x"), 16)
+	if !okx || !oky {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// ShowEndpoints checks the endpoints of the network and prints their health
+func (cli *CLI) ShowEndpoints() error {
+	urls := cli.Network.Endpoints
+	if len(urls) == 0 {
+		urls = []string{cli.NetworkURL}
+	}
+	maxLag := cli.Network.MaxLag
+	if maxLag == 0 {
+		maxLag = defaultMaxLag
+	}
+	endpoints := checkEndpoints(context.Background(), urls, cli.Network.ChainID, maxLag)
+	for _, e := range endpoints {
+		if e.rc != nil {
+			e.rc.Close()
+		}
+		if !e.Healthy() {
+			fmt.Printf("%-40s unhealthy: %v\n", e.URL, e.Err)
+			continue
+		}
+		fmt.Printf("%-40s ok  chain %d  block %d  %v\n", e.URL, e.ChainID, e.Head, e.Latency.Round(time.Microsecond))
+	}
+	if cli.Network.Quorum > 1 {
+		fmt.Printf("quorum: %d endpoints must agree\n", cli.Network.Quorum)
+	}
+	return nil
+}
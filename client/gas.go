@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// feeHistoryBlocks is the number of recent blocks the fee strategy looks at
+const feeHistoryBlocks = 20
+
+// gasSpeeds maps a gas price strategy to the percentile of the priority
+// fees paid in recent blocks
+var gasSpeeds = map[string]float64{
+	"slow":   10,
+	"normal": 50,
+	"fast":   90,
+}
+
+// gasPriceFactors scales eth_gasPrice when the node has no fee history
+var gasPriceFactors = map[string][2]int64{
+	"slow":   {9, 10},
+	"normal": {1, 1},
+	"fast":   {5, 4},
+}
+
+// TxOptions overrides the gas, gas price and data of a transaction, zero
+// values are estimated from the network
+type TxOptions struct {
+	Gas uint64
+	// GasPrice is the gas price of legacy transactions and the max fee per
+	// gas of dynamic fee (EIP-1559) ones
+	GasPrice  *big.Int
+	GasTipCap *big.Int // max priority fee per gas, dynamic fee transactions only
+	Data      []byte
+	Speed     string // slow, normal or fast
+	Legacy    bool   // legacy transaction, also set by fillFees on networks without base fee
+	Force     bool   // send even if the eth_call simulation fails
+}
+
+// ParseTxOptions builds TxOptions from the -gas, -gasprice, -data, -speed and -legacy flags
+func ParseTxOptions(gas uint64, gasPrice, data, speed string, legacy bool) (TxOptions, error) {
+	opts := TxOptions{Gas: gas, Speed: speed, Legacy: legacy}
+	if _, ok := gasSpeeds[speed]; !ok {
+		return opts, fmt.Errorf("unknown speed %q, want slow, normal or fast", speed)
+	}
+	if gasPrice != "" {
+		price, err := utils.ParseAmount(gasPrice)
+		if err != nil {
+			return opts, err
+		}
+		opts.GasPrice = price
+	}
+	if data != "" {
+		b, err := hexutil.Decode(data)
+		if err != nil {
+			return opts, fmt.Errorf("invalid -data %q: %v", data, err)
+		}
+		opts.Data = b
+	}
+	return opts, nil
+}
+
+type feeHistory struct {
+	BaseFee []*hexutil.Big   `json:"baseFeePerGas"`
+	Reward  [][]*hexutil.Big `json:"reward"`
+}
+
+// suggestFees returns the base fee of the next block and the priority fee
+// paid at the percentile of speed over the recent blocks
+func suggestFees(ctx context.Context, rc *rpc.Client, speed string) (baseFee, tip *big.Int, err error) {
+	var history feeHistory
+	err = rc.CallContext(ctx, &history, "eth_feeHistory", hexutil.Uint(feeHistoryBlocks), "latest", []float64{gasSpeeds[speed]})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(history.BaseFee) == 0 || len(history.Reward) == 0 {
+		return nil, nil, fmt.Errorf("no fee history")
+	}
+	tips := make([]*big.Int, 0, len(history.Reward))
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			tips = append(tips, reward[0].ToInt())
+		}
+	}
+	if len(tips) == 0 {
+		return nil, nil, fmt.Errorf("no fee history")
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	// the last base fee is the one of the next block
+	return history.BaseFee[len(history.BaseFee)-1].ToInt(), tips[len(tips)/2], nil
+}
+
+// maxFee is the max fee per gas that still pays tip when the base fee
+// doubles, i.e. after two full blocks
+func maxFee(baseFee, tip *big.Int) *big.Int {
+	return new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), tip)
+}
+
+// suggestGasPrice picks a gas price for speed of a legacy transaction.
+// headBaseFee is the base fee of the latest block, nil before London: on
+// networks with a base fee the price is the max fee of a dynamic fee
+// transaction, the base fee may rise before the transaction is mined. Nodes
+// without fee history fall back to eth_gasPrice.
+func suggestGasPrice(ctx context.Context, rc *rpc.Client, speed string, headBaseFee *big.Int) (*big.Int, error) {
+	if baseFee, tip, err := suggestFees(ctx, rc, speed); err == nil {
+		return maxFee(baseFee, tip), nil
+	}
+	if headBaseFee != nil {
+		tip, err := ethclient.NewClient(rc).SuggestGasTipCap(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return maxFee(headBaseFee, tip), nil
+	}
+	price, err := ethclient.NewClient(rc).SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f := gasPriceFactors[speed]
+	price.Mul(price, big.NewInt(f[0]))
+	return price.Div(price, big.NewInt(f[1])), nil
+}
+
+// fillFees sets the fees of opts that were not given. Networks with a base
+// fee get dynamic fee transactions unless opts is legacy: the tip comes from
+// the fee history and the max fee leaves room for two full blocks.
+func fillFees(ctx context.Context, rc *rpc.Client, opts *TxOptions) error {
+	head, err := ethclient.NewClient(rc).HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if head.BaseFee == nil {
+		opts.Legacy = true
+	}
+	if opts.Legacy {
+		if opts.GasPrice == nil {
+			price, err := suggestGasPrice(ctx, rc, opts.Speed, head.BaseFee)
+			if err != nil {
+				return fmt.Errorf("failed to get gas price: %v", err)
+			}
+			opts.GasPrice = price
+		}
+		return nil
+	}
+
+	baseFee, tip, err := suggestFees(ctx, rc, opts.Speed)
+	if err != nil {
+		// nodes without fee history still suggest a tip
+		baseFee = head.BaseFee
+		if tip, err = ethclient.NewClient(rc).SuggestGasTipCap(ctx); err != nil {
+			return fmt.Errorf("failed to get priority fee: %v", err)
+		}
+	}
+	if opts.GasPrice == nil {
+		opts.GasPrice = maxFee(baseFee, tip)
+	}
+	if opts.GasTipCap == nil {
+		opts.GasTipCap = tip
+	}
+	// the tip can't be higher than the max fee
+	if opts.GasTipCap.Cmp(opts.GasPrice) > 0 {
+		opts.GasTipCap = new(big.Int).Set(opts.GasPrice)
+	}
+	return nil
+}
+
+// fillGas sets the gas limit and fees of opts that were not given
+func fillGas(ctx context.Context, rc *rpc.Client, msg ethereum.CallMsg, opts *TxOptions) error {
+	if opts.Gas == 0 {
+		gas, err := ethclient.NewClient(rc).EstimateGas(ctx, msg)
+		if err != nil {
+			return fmt.Errorf("failed to estimate gas: %v", err)
+		}
+		opts.Gas = gas
+	}
+	return fillFees(ctx, rc, opts)
+}
+
+// newTx builds the transaction of msg with the gas and fees of opts, a
+// dynamic fee transaction unless opts is legacy
+func newTx(nonce uint64, msg ethereum.CallMsg, opts TxOptions, chainID *big.Int) *types.Transaction {
+	if opts.Legacy || chainID == nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       msg.To,
+			Value:    msg.Value,
+			Gas:      opts.Gas,
+			GasPrice: opts.GasPrice,
+			Data:     msg.Data,
+		})
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		To:        msg.To,
+		Value:     msg.Value,
+		Gas:       opts.Gas,
+		GasFeeCap: opts.GasPrice,
+		GasTipCap: opts.GasTipCap,
+		Data:      msg.Data,
+	})
+}
+
+// chainID returns the chain id transactions are signed for: the one of the
+// network profile, otherwise the one of the node. It is nil only with
+// cli.Unprotected on a node that doesn't know eth_chainId, transactions are
+// then signed without replay protection; any other failure is an error.
+func (cli *CLI) chainID(ctx context.Context, rc *rpc.Client) (*big.Int, error) {
+	if cli.Network.ChainID != 0 {
+		return new(big.Int).SetUint64(cli.Network.ChainID), nil
+	}
+	id, err := ethclient.NewClient(rc).ChainID(ctx)
+	if err == nil {
+		return id, nil
+	}
+	// a node without eth_chainId answers with an error, a timeout doesn't
+	if _, isRPC := err.(rpc.Error); isRPC && cli.Unprotected {
+		log.Printf("WARNING: the node has no chain id (%v), signing without replay protection\n", err)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("failed to get the chain id: %v, use -unprotected to sign without replay protection on nodes without eth_chainId", err)
+}
+
+// checkFunds refuses to send tx if the balance of from can't pay its value
+// plus the maximum fee
+func checkFunds(ctx context.Context, rc *rpc.Client, from common.Address, tx *types.Transaction) error {
+	balance, err := ethclient.NewClient(rc).BalanceAt(ctx, from, nil)
+	if err != nil {
+		return err
+	}
+	cost := tx.Cost()
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("insufficient funds: balance %s ether, need %s ether for value plus max fee",
+			utils.FormatEther(balance), utils.FormatEther(cost))
+	}
+	return nil
+}
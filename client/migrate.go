@@ -0,0 +1,104 @@
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"wallet/keystorecode"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// walletDB is the database of a wallet using the db storage backend
+func (cli *CLI) walletDB(name string) string {
+	return filepath.Join(cli.DataPath, name+".db")
+}
+
+// openKeyStore opens the keystore of the wallet, from its database when
+// it was migrated to one, read-only so that the agent or signer serving it
+// doesn't lock the other commands out, from its key files otherwise
+func (cli *CLI) openKeyStore(name string) (*keystorecode.KeyStore, error) {
+	if _, err := os.Stat(cli.walletDB(name)); err == nil {
+		return keystorecode.NewReadOnlyDBKeyStore(cli.walletDB(name), keystorecode.LightScryptN, keystorecode.LightScryptP)
+	}
+	return keystorecode.NewKeyStore(filepath.Join(cli.DataPath, name), keystorecode.LightScryptN, keystorecode.LightScryptP), nil
+}
+
+// dbAccountKey decrypts the key of account from the database of the wallet
+func (cli *CLI) dbAccountKey(name string, account common.Address, auth string) (*keystore.Key, error) {
+	ks, err := keystorecode.NewReadOnlyDBKeyStore(cli.walletDB(name), keystorecode.LightScryptN, keystorecode.LightScryptP)
+	if err != nil {
+		return nil, err
+	}
+	defer ks.Close()
+	key, err := ks.GetKey(accounts.Account{Address: account}, auth)
+	if err != nil {
+		return nil, err
+	}
+	k := &keystore.Key{Address: key.Address, PrivateKey: key.PrivateKey}
+	copy(k.Id[:], key.Id)
+	return k, nil
+}
+
+// Migrate moves the keys of the wallet between its key files ("file") and
+// its database ("db"), the source is only removed if remove is set
+func (cli *CLI) Migrate(name, to string, remove bool) error {
+	dir, db := filepath.Join(cli.DataPath, name), cli.walletDB(name)
+
+	switch to {
+	case "db":
+		n, err := keystorecode.MigrateFilesToDB(dir, db)
+		if err != nil {
+			return fmt.Errorf("migrated %d keys before failing: %v", n, err)
+		}
+		log.Printf("migrated %d keys of wallet %s to %s\n", n, name, db)
+		if remove {
+			return removeMigratedFiles(dir, db)
+		}
+	case "file":
+		n, err := keystorecode.MigrateDBToFiles(db, dir)
+		if err != nil {
+			return fmt.Errorf("migrated %d keys before failing: %v", n, err)
+		}
+		log.Printf("migrated %d keys of wallet %s to %s\n", n, name, dir)
+		if remove {
+			log.Println("removing", db)
+			return os.Remove(db)
+		}
+	default:
+		return fmt.Errorf("unknown storage %q, want db or file", to)
+	}
+	return nil
+}
+
+// removeMigratedFiles removes the key files of dir whose account is in the database
+func removeMigratedFiles(dir, db string) error {
+	ks, err := keystorecode.NewReadOnlyDBKeyStore(db, keystorecode.LightScryptN, keystorecode.LightScryptP)
+	if err != nil {
+		return err
+	}
+	defer ks.Close()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		path := filepath.Join(dir, fi.Name())
+		keyjson, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if addr, ok := keyFileAddress(keyjson); ok && ks.HasAddress(addr) {
+			log.Println("removing", path)
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"wallet/hdwallet"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+networks.json 定义可以使用的网络, 通过全局参数 -network NAME 选择:
+
+	{
+	  "default": "dev",
+	  "networks": {
+	    "dev":     {"rpc": "http://localhost:8545", "chainId": 1337, "tokens": "tokens.json"},
+	    "sepolia": {"rpc": "https://rpc.sepolia.org", "chainId": 11155111,
+	                "explorer": "https://sepolia.etherscan.io/tx/{hash}", "coinType": 1},
+	    "mainnet": {"endpoints": ["https://a.example", "https://b.example", "wss://c.example"],
+	                "chainId": 1, "quorum": 2, "maxLag": 5,
+	                "multicall": "0xcA11bde05977b3631167028862bE2a173976CA11"}
+	  }
+	}
+
+有多个 endpoints 时先检查每个节点的 chain id, 区块高度和延迟, 读请求在节点失败时
+自动切换到下一个节点; quorum 为 N 时, 签名前要求 N 个节点对账户余额和 nonce 的结果一致.
+
+没有 networks.json 时使用 main.go 中的节点和 tokens.json, 不检查 chain id.
+*/
+
+// defaultCoinType is the BIP-44 coin type of ether, m/44'/60'/0'/0/i
+const defaultCoinType = 60
+
+// Network is a network profile of the networks file
+type Network struct {
+	Name string `json:"-"`
+	// RPC is the url of the node
+	RPC string `json:"rpc"`
+	// Endpoints are more urls of nodes of the network, the rpc url first
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Quorum is the number of endpoints that must agree on the balance and
+	// nonce of an account before signing, 0 or 1 not to compare
+	Quorum int `json:"quorum,omitempty"`
+	// MaxLag is how many blocks an endpoint may be behind, 5 if not set
+	MaxLag uint64 `json:"maxLag,omitempty"`
+	// ChainID is the chain id the node must report
+	ChainID uint64 `json:"chainId"`
+	// Explorer is the url of a transaction with {hash} in place of its hash
+	Explorer string `json:"explorer,omitempty"`
+	// CoinType is the BIP-44 coin type of the derived accounts, 60 if not set
+	CoinType uint32 `json:"coinType,omitempty"`
+	// Tokens is the token registry file, tokens.NAME.json if not set
+	Tokens string `json:"tokens,omitempty"`
+	// Multicall is the address of a Multicall3 contract to read many
+	// balances in one call, JSON-RPC batches are used if not set
+	Multicall string `json:"multicall,omitempty"`
+}
+
+// NetworksConfig is the content of the networks file
+type NetworksConfig struct {
+	Default  string              `json:"default"`
+	Networks map[string]*Network `json:"networks"`
+}
+
+// LoadNetworks reads the networks file, nil if it does not exist
+func LoadNetworks(file string) (*NetworksConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := new(NetworksConfig)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid networks file %s: %v", file, err)
+	}
+	for name, n := range config.Networks {
+		if n == nil || (n.RPC == "" && len(n.Endpoints) == 0) {
+			return nil, fmt.Errorf("network %s in %s has no rpc url", name, file)
+		}
+		n.Endpoints = endpointList(n.RPC, n.Endpoints)
+		n.RPC = n.Endpoints[0]
+		for _, u := range n.Endpoints {
+			if _, err := nodeTransport(u); err != nil {
+				return nil, fmt.Errorf("network %s in %s: %v", name, file, err)
+			}
+		}
+		if n.Quorum > len(n.Endpoints) {
+			return nil, fmt.Errorf("network %s in %s: quorum %d with %d endpoints", name, file, n.Quorum, len(n.Endpoints))
+		}
+		if n.MaxLag == 0 {
+			n.MaxLag = defaultMaxLag
+		}
+		if n.ChainID == 0 {
+			return nil, fmt.Errorf("network %s in %s has no chainId", name, file)
+		}
+		n.Name = name
+		if n.CoinType == 0 {
+			n.CoinType = defaultCoinType
+		}
+		if n.Multicall != "" && !common.IsHexAddress(n.Multicall) {
+			return nil, fmt.Errorf("network %s in %s: invalid multicall address %s", name, file, n.Multicall)
+		}
+		if n.Tokens == "" {
+			n.Tokens = "tokens." + name + ".json"
+		}
+	}
+	if config.Default != "" && config.Networks[config.Default] == nil {
+		return nil, fmt.Errorf("default network %s is not in %s", config.Default, file)
+	}
+	return config, nil
+}
+
+// endpointList is the rpc url followed by the other endpoints, without
+// duplicates
+func endpointList(rpc string, endpoints []string) []string {
+	var list []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{rpc}, endpoints...) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			list = append(list, u)
+		}
+	}
+	return list
+}
+
+// names lists the networks of the config in order
+func (config *NetworksConfig) names() []string {
+	names := make([]string, 0, len(config.Networks))
+	for name := range config.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseNetwork selects the network name of the networks file, its default
+// network, or its only one if name is empty
+func (cli *CLI) UseNetwork(name string) error {
+	config, err := LoadNetworks(cli.NetworksFile)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		if name != "" {
+			return fmt.Errorf("network %s: no networks file %s", name, cli.NetworksFile)
+		}
+		return nil
+	}
+	if name == "" {
+		name = config.Default
+	}
+	if name == "" && len(config.Networks) == 1 {
+		name = config.names()[0]
+	}
+	if name == "" {
+		return fmt.Errorf("choose a network of %s with -network: %s", cli.NetworksFile, strings.Join(config.names(), ", "))
+	}
+	n, ok := config.Networks[name]
+	if !ok {
+		return fmt.Errorf("network %s is not in %s: %s", name, cli.NetworksFile, strings.Join(config.names(), ", "))
+	}
+	cli.Network = n
+	cli.NetworkURL = n.RPC
+	cli.TokensFile = n.Tokens
+	return nil
+}
+
+// checkChainID verifies that the node reports the chain id of the network,
+// so that addresses and transactions of one network are not used on another
+func (cli *CLI) checkChainID() error {
+	if cli.Network.ChainID == 0 {
+		return nil
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	id, err := client.ChainID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get the chain id of %s: %v", cli.NetworkURL, err)
+	}
+	if !id.IsUint64() || id.Uint64() != cli.Network.ChainID {
+		return fmt.Errorf("the node %s is on chain %s, network %s expects chain %d", cli.NetworkURL, id, cli.Network.Name, cli.Network.ChainID)
+	}
+	return nil
+}
+
+// accountPath is the derivation path of the i-th account of coinType
+func accountPath(coinType uint32, i int) accounts.DerivationPath {
+	return hdwallet.MustParseDerivationPath(fmt.Sprintf("m/44'/%d'/0'/0/%d", coinType, i))
+}
+
+// explorerURL is the explorer page of the transaction hash, empty if the
+// network has no explorer
+func (cli *CLI) explorerURL(hash common.Hash) string {
+	if cli.Network.Explorer == "" {
+		return ""
+	}
+	return strings.Replace(cli.Network.Explorer, "{hash}", hash.Hex(), -1)
+}
+
+// logExplorer prints the explorer page of a sent transaction
+func (cli *CLI) logExplorer(hash common.Hash) {
+	if url := cli.explorerURL(hash); url != "" {
+		log.Println("explorer: ", url)
+	}
+}
+
+// ShowNetworks prints the networks of the networks file, the selected one marked
+func (cli *CLI) ShowNetworks() error {
+	config, err := LoadNetworks(cli.NetworksFile)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		fmt.Printf("no networks file %s, using %s\n", cli.NetworksFile, cli.NetworkURL)
+		return nil
+	}
+	for _, name := range config.names() {
+		n := config.Networks[name]
+		mark := " "
+		if name == cli.Network.Name {
+			mark = "*"
+		}
+		fmt.Printf("%s %-12s chain %-10d %s tokens: %s coin type: %d", mark, name, n.ChainID, strings.Join(n.Endpoints, ","), n.Tokens, n.CoinType)
+		if n.Quorum > 1 {
+			fmt.Printf(" quorum: %d", n.Quorum)
+		}
+		if n.Explorer != "" {
+			fmt.Printf(" explorer: %s", n.Explorer)
+		}
+		fmt.Println()
+	}
+	return nil
+}
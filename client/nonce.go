@@ -0,0 +1,430 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+NonceManager 在 DataPath/nonces.db 中记录每个账户已分配的 nonce 和发送的交易:
+以节点的 pending nonce 为准分配 nonce, 跳过其他进程已预留的 nonce,
+发现被节点丢弃的交易和 nonce 空缺. bolt 的文件锁保证多个 wallet 进程同时分配时不会冲突.
+*/
+
+// nonceReserveTimeout is how long a nonce allocated to a transaction that
+// was not broadcast yet stays taken, e.g. while the password is typed.
+// Nonces of offline transactions stay taken until broadcast or released.
+const nonceReserveTimeout = 10 * time.Minute
+
+// states of a NonceRecord
+const (
+	NonceReserved = "reserved" // allocated, not broadcast yet
+	NoncePending  = "pending"  // broadcast, not mined yet
+	NonceMined    = "mined"    // the nonce was used on chain
+	NonceDropped  = "dropped"  // broadcast but no longer known by the node
+)
+
+// NonceRecord is a nonce allocated to a transaction of an account
+type NonceRecord struct {
+	Nonce  uint64        `json:"nonce"`
+	Status string        `json:"status"`
+	Hash   common.Hash   `json:"hash"`
+	Raw    hexutil.Bytes `json:"raw,omitempty"` // signed transaction, to resend or replace it
+	Time   time.Time     `json:"time"`
+	// Offline is set on the reservations of buildtx: signing on another
+	// machine takes any time, they don't expire
+	Offline bool `json:"offline,omitempty"`
+	// Replaced lists the earlier transactions sent with this nonce, e.g.
+	// before a speedup or cancel
+	Replaced []common.Hash `json:"replaced,omitempty"`
+}
+
+// NonceManager allocates the nonces of the accounts of the wallet
+type NonceManager struct {
+	db *bolt.DB
+}
+
+// OpenNonceManager opens the nonce database path. It is locked until Close,
+// other processes wait for it.
+func OpenNonceManager(path string) (*NonceManager, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 30 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open nonce database %s: %v", path, err)
+	}
+	return &NonceManager{db: db}, nil
+}
+
+// Close releases the nonce database
+func (nm *NonceManager) Close() error {
+	return nm.db.Close()
+}
+
+func nonceKey(nonce uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, nonce)
+	return key
+}
+
+func putNonceRecord(b *bolt.Bucket, rec *NonceRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return b.Put(nonceKey(rec.Nonce), data)
+}
+
+// nonceRecords returns the records of the bucket sorted by nonce
+func nonceRecords(b *bolt.Bucket) ([]*NonceRecord, error) {
+	var recs []*NonceRecord
+	if b == nil {
+		return recs, nil
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		rec := new(NonceRecord)
+		if err := json.Unmarshal(v, rec); err != nil {
+			return err
+		}
+		recs = append(recs, rec)
+		return nil
+	})
+	// keys are big endian, ForEach already walks them in nonce order
+	return recs, err
+}
+
+// Records returns the records of account sorted by nonce
+func (nm *NonceManager) Records(account common.Address) (recs []*NonceRecord, err error) {
+	err = nm.db.View(func(tx *bolt.Tx) error {
+		recs, err = nonceRecords(tx.Bucket(account.Bytes()))
+		return err
+	})
+	return recs, err
+}
+
+// Find returns the account and the record of the transaction hash, also
+// when it was replaced by a later transaction with the same nonce
+func (nm *NonceManager) Find(hash common.Hash) (account common.Address, found *NonceRecord, err error) {
+	err = nm.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			recs, err := nonceRecords(b)
+			if err != nil {
+				return err
+			}
+			for _, rec := range recs {
+				if rec.Hash == hash || containsHash(rec.Replaced, hash) {
+					account, found = common.BytesToAddress(name), rec
+				}
+			}
+			return nil
+		})
+	})
+	if err == nil && found == nil {
+		err = fmt.Errorf("transaction %s was not sent by this wallet", hash.Hex())
+	}
+	return account, found, err
+}
+
+func containsHash(hashes []common.Hash, hash common.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// sync updates the records of account from the node: nonces below the
+// confirmed nonce are mined, transactions the node doesn't know any more
+// are dropped and online reservations older than nonceReserveTimeout, left
+// by sends that failed before the broadcast, are released.
+func (nm *NonceManager) sync(ctx context.Context, client *ethclient.Client, b *bolt.Bucket, account common.Address) (confirmed uint64, err error) {
+	confirmed, err = client.NonceAt(ctx, account, nil)
+	if err != nil {
+		return 0, err
+	}
+	recs, err := nonceRecords(b)
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range recs {
+		status := rec.Status
+		switch {
+		case rec.Status == NonceReserved && (rec.Nonce < confirmed || !rec.Offline && time.Since(rec.Time) > nonceReserveTimeout):
+			// never broadcast
+			if err := b.Delete(nonceKey(rec.Nonce)); err != nil {
+				return 0, err
+			}
+			continue
+		case rec.Nonce < confirmed:
+			status = NonceMined
+		case rec.Status == NoncePending:
+			if _, _, err := client.TransactionByHash(ctx, rec.Hash); err == ethereum.NotFound {
+				status = NonceDropped
+				log.Printf("transaction %s nonce %d of %s was dropped by the node\n", rec.Hash.Hex(), rec.Nonce, account.Hex())
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		if status != rec.Status {
+			rec.Status = status
+			if err := putNonceRecord(b, rec); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return confirmed, nil
+}
+
+// Reserve allocates the next nonce of account: the pending nonce of the
+// node, skipping nonces reserved or pending in other invocations. A nonce
+// of a dropped transaction or below queued ones is reused to fill the gap.
+// An offline reservation is kept until the transaction is broadcast.
+func (nm *NonceManager) Reserve(ctx context.Context, client *ethclient.Client, account common.Address, offline bool) (nonce uint64, err error) {
+	pending, err := client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	err = nm.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(account.Bytes())
+		if err != nil {
+			return err
+		}
+		confirmed, err := nm.sync(ctx, client, b, account)
+		if err != nil {
+			return err
+		}
+		recs, err := nonceRecords(b)
+		if err != nil {
+			return err
+		}
+
+		taken := make(map[uint64]bool)
+		for _, rec := range recs {
+			if rec.Status == NonceReserved || rec.Status == NoncePending {
+				taken[rec.Nonce] = true
+			}
+		}
+		nonce = pending
+		if nonce < confirmed {
+			nonce = confirmed
+		}
+		for taken[nonce] {
+			nonce++
+		}
+		if gaps := nonceGaps(confirmed, recs); len(gaps) > 0 {
+			log.Printf("nonces %v of %s are missing, later transactions wait for them\n", gaps, account.Hex())
+		}
+		return putNonceRecord(b, &NonceRecord{Nonce: nonce, Status: NonceReserved, Time: time.Now(), Offline: offline})
+	})
+	return nonce, err
+}
+
+// Sent records the broadcast transaction tx of account
+func (nm *NonceManager) Sent(account common.Address, tx *types.Transaction) error {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return nm.db.Update(func(btx *bolt.Tx) error {
+		b, err := btx.CreateBucketIfNotExists(account.Bytes())
+		if err != nil {
+			return err
+		}
+		rec := &NonceRecord{Nonce: tx.Nonce(), Status: NoncePending, Hash: tx.Hash(), Raw: raw, Time: time.Now()}
+		if v := b.Get(nonceKey(tx.Nonce())); v != nil {
+			old := new(NonceRecord)
+			if err := json.Unmarshal(v, old); err != nil {
+				return err
+			}
+			rec.Replaced = old.Replaced
+			if old.Hash != (common.Hash{}) && old.Hash != tx.Hash() {
+				rec.Replaced = append(rec.Replaced, old.Hash)
+			}
+		}
+		return putNonceRecord(b, rec)
+	})
+}
+
+// Release frees the nonce of account if its transaction was not broadcast
+func (nm *NonceManager) Release(account common.Address, nonce uint64) error {
+	return nm.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(account.Bytes())
+		if b == nil {
+			return nil
+		}
+		v := b.Get(nonceKey(nonce))
+		if v == nil {
+			return nil
+		}
+		rec := new(NonceRecord)
+		if err := json.Unmarshal(v, rec); err != nil {
+			return err
+		}
+		if rec.Status != NonceReserved {
+			return nil
+		}
+		return b.Delete(nonceKey(nonce))
+	})
+}
+
+// nonceGaps returns the nonces from confirmed on that have no live
+// transaction while a higher nonce has one: the node queues the higher ones
+func nonceGaps(confirmed uint64, recs []*NonceRecord) []uint64 {
+	live := make(map[uint64]bool)
+	var highest uint64
+	for _, rec := range recs {
+		if rec.Nonce >= confirmed && (rec.Status == NonceReserved || rec.Status == NoncePending) {
+			live[rec.Nonce] = true
+			if rec.Nonce+1 > highest {
+				highest = rec.Nonce + 1
+			}
+		}
+	}
+	var gaps []uint64
+	for n := confirmed; n < highest; n++ {
+		if !live[n] {
+			gaps = append(gaps, n)
+		}
+	}
+	return gaps
+}
+
+// nonceDB is the nonce database of the wallet data directory, one per
+// network profile since nonces of the same account differ between chains
+func (cli *CLI) nonceDB() string {
+	if cli.Network.Name != "" {
+		return filepath.Join(cli.DataPath, "nonces."+cli.Network.Name+".db")
+	}
+	return filepath.Join(cli.DataPath, "nonces.db")
+}
+
+// reserveNonce allocates the next nonce of from with the nonce manager,
+// offline for a transaction signed on another machine
+func (cli *CLI) reserveNonce(ctx context.Context, client *ethclient.Client, from common.Address, offline bool) (uint64, error) {
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		return 0, err
+	}
+	defer nm.Close()
+	return nm.Reserve(ctx, client, from, offline)
+}
+
+// releaseNonce frees the nonce reserved for a transaction that won't be sent
+func (cli *CLI) releaseNonce(from common.Address, nonce uint64) {
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		log.Println("failed to release nonce: ", err)
+		return
+	}
+	defer nm.Close()
+	if err := nm.Release(from, nonce); err != nil {
+		log.Println("failed to release nonce: ", err)
+	}
+}
+
+// recordSent records the broadcast transaction tx of from
+func (cli *CLI) recordSent(from common.Address, tx *types.Transaction) {
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		log.Println("failed to record transaction: ", err)
+		return
+	}
+	defer nm.Close()
+	if err := nm.Sent(from, tx); err != nil {
+		log.Println("failed to record transaction: ", err)
+	}
+}
+
+// ReleaseNonce frees the nonce of account reserved for a transaction that
+// won't be broadcast, e.g. an unsigned transaction of buildtx thrown away
+func (cli *CLI) ReleaseNonce(account string, nonce uint64) error {
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		return err
+	}
+	defer nm.Close()
+	addr := common.HexToAddress(account)
+	recs, err := nm.Records(addr)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if rec.Nonce != nonce {
+			continue
+		}
+		if rec.Status != NonceReserved {
+			return fmt.Errorf("nonce %d of %s is %s, only reserved nonces can be released", nonce, addr.Hex(), rec.Status)
+		}
+		if err := nm.Release(addr, nonce); err != nil {
+			return err
+		}
+		log.Printf("nonce %d of %s released\n", nonce, addr.Hex())
+		return nil
+	}
+	return fmt.Errorf("nonce %d of %s is not reserved", nonce, addr.Hex())
+}
+
+// ShowNonces prints the nonces of the node and the transactions sent from
+// account by the wallet, with dropped transactions and missing nonces
+func (cli *CLI) ShowNonces(account string) error {
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	addr := common.HexToAddress(account)
+
+	pending, err := client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return err
+	}
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		return err
+	}
+	defer nm.Close()
+
+	var confirmed uint64
+	err = nm.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(addr.Bytes())
+		if err != nil {
+			return err
+		}
+		confirmed, err = nm.sync(ctx, client, b, addr)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	recs, err := nm.Records(addr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("account:   %s\nconfirmed: %d\npending:   %d\n", addr.Hex(), confirmed, pending)
+	for _, rec := range recs {
+		note := ""
+		if rec.Status == NonceReserved && rec.Offline {
+			note = "  buildtx, broadcast it or release it with -release"
+		}
+		fmt.Printf("%6d  %-8s  %s  %s%s\n", rec.Nonce, rec.Status, rec.Hash.Hex(), rec.Time.Format("2006-01-02 15:04:05"), note)
+	}
+	if gaps := nonceGaps(confirmed, recs); len(gaps) > 0 {
+		fmt.Printf("missing nonces: %v, the transactions after them wait in the node queue\n", gaps)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"strings"
+
+	"wallet/abi"
+	"wallet/hdkeystore"
+	"wallet/hdwallet"
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// OfflineTx is the unsigned transaction written by buildtx and signed by signtx
+type OfflineTx struct {
+	SendTxArgs
+	// Symbol names the token of a token transfer and Decimals are its
+	// decimals, for the summary only: the calldata is decoded again before
+	// signing
+	Symbol   string `json:"symbol,omitempty"`
+	Decimals *uint8 `json:"decimals,omitempty"`
+}
+
+// erc20ABI is the token abi used to encode and decode transfers
+var erc20ABI, _ = ethabi.JSON(strings.NewReader(abi.ERC20ABI))
+
+// BuildTx fetches the nonce, gas, fees and chain id of a transfer of amount
+// from to, in ether with a unit or in units of the token symbol if set, and
+// writes the unsigned transaction to out
+func (cli *CLI) BuildTx(from, to, symbol, amount string, opts TxOptions, out string) error {
+	rclient, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	fromAddr, toAddr := common.HexToAddress(from), common.HexToAddress(to)
+	otx := OfflineTx{Symbol: symbol}
+	var msg ethereum.CallMsg
+	if symbol != "" {
+		token, err := cli.lookupToken(ctx, symbol)
+		if err != nil {
+			return err
+		}
+		value, err := token.Parse(amount)
+		if err != nil {
+			return err
+		}
+		data, err := erc20ABI.Pack("transfer", toAddr, value)
+		if err != nil {
+			return err
+		}
+		msg = ethereum.CallMsg{From: fromAddr, To: &token.Address, Value: new(big.Int), Data: data}
+		otx.Decimals = &token.Decimals
+	} else {
+		value, err := utils.ParseAmount(amount)
+		if err != nil {
+			return err
+		}
+		msg = ethereum.CallMsg{From: fromAddr, To: &toAddr, Value: value, Data: opts.Data}
+	}
+
+	if err := preflight(ctx, rclient, msg, opts.Force); err != nil {
+		return err
+	}
+	var token *common.Address
+	if symbol != "" {
+		token = msg.To
+	}
+	if err := cli.checkQuorum(ctx, fromAddr, token); err != nil {
+		return err
+	}
+	id, err := cli.chainID(ctx, rclient)
+	if err != nil {
+		return err
+	}
+	if id == nil {
+		opts.Legacy = true
+	}
+	if err := fillGas(ctx, rclient, msg, &opts); err != nil {
+		return err
+	}
+	// the nonce stays reserved until broadcast records it or nonces -release
+	nonce, err := cli.reserveNonce(ctx, ethclient.NewClient(rclient), fromAddr, true)
+	if err != nil {
+		return err
+	}
+	tx := newTx(nonce, msg, opts, id)
+	if err := checkFunds(ctx, rclient, fromAddr, tx); err != nil {
+		cli.releaseNonce(fromAddr, nonce)
+		return err
+	}
+
+	otx.SendTxArgs = txArgs(fromAddr, tx, id)
+	data, err := json.MarshalIndent(otx, "", "  ")
+	if err != nil {
+		cli.releaseNonce(fromAddr, nonce)
+		return err
+	}
+	if err := utils.WriteKeyFile(out, data); err != nil {
+		cli.releaseNonce(fromAddr, nonce)
+		return err
+	}
+	log.Printf("unsigned transaction nonce %d written to %s\n", nonce, out)
+	return nil
+}
+
+// offlineSummary describes the unsigned transaction, token transfers are
+// decoded from the calldata
+func offlineSummary(otx *OfflineTx, tx *types.Transaction) string {
+	summary := transferSummary(otx.From.Hex(), tx)
+	if otx.ChainID != nil {
+		summary += fmt.Sprintf("\nchain id:  %s", otx.ChainID.ToInt())
+	} else {
+		summary += "\nchain id:  none, no replay protection"
+	}
+	summary += fmt.Sprintf("\nnonce:     %d", tx.Nonce())
+	return summary + dataSummary(tx, otx.Symbol, otx.Decimals)
+}
+
+// dataSummary describes the calldata of tx, decoding token transfers and
+// approvals of the token symbol, in token units if its decimals are known
+func dataSummary(tx *types.Transaction, symbol string, decimals *uint8) string {
+	data := tx.Data()
+	if len(data) < 4 {
+		return ""
+	}
+	if tx.To() != nil {
+		if summary := erc20CallSummary(*tx.To(), data, symbol, decimals); summary != "" {
+			return summary
+		}
+	}
+	return fmt.Sprintf("\ndata:      %s", hexutil.Encode(data))
+}
+
+// erc20CallSummary describes a call of transfer, approve or transferFrom of
+// token, empty for other calldata
+func erc20CallSummary(token common.Address, data []byte, symbol string, decimals *uint8) string {
+	method, err := erc20ABI.MethodById(data[:4])
+	if err != nil {
+		return ""
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil || len(args) < 2 {
+		return ""
+	}
+	value, ok := args[len(args)-1].(*big.Int)
+	if !ok {
+		return ""
+	}
+	amount := value.String()
+	if method.Name == "approve" && value.Cmp(math.MaxBig256) == 0 {
+		amount = "unlimited"
+	} else if decimals != nil {
+		amount = fmt.Sprintf("%s %s (%s base units)", utils.FormatUnits(value, int(*decimals)), symbol, value)
+	}
+	summary := fmt.Sprintf("\ntoken:     %s %s", token.Hex(), symbol)
+	switch method.Name {
+	case "transfer":
+		return summary + fmt.Sprintf("\ntransfer:  %s to %s", amount, args[0].(common.Address).Hex())
+	case "approve":
+		return summary + fmt.Sprintf("\napprove:   %s to spender %s", amount, args[0].(common.Address).Hex())
+	case "transferFrom":
+		return summary + fmt.Sprintf("\ntransfer:  %s from %s to %s", amount, args[0].(common.Address).Hex(), args[1].(common.Address).Hex())
+	}
+	return ""
+}
+
+// deriveHDWallet derives the first count accounts of coinType of the hd
+// wallet of mnemonic
+func deriveHDWallet(mnemonic string, count int, coinType uint32) (*hdwallet.Wallet, error) {
+	wallet, err := hdwallet.NewFromMnemonic(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		if _, err := wallet.Derive(accountPath(coinType, i), true); err != nil {
+			return nil, err
+		}
+	}
+	return wallet, nil
+}
+
+// SignOfflineTx signs the unsigned transaction file in with the keystore
+// file of its sender, or with the hd wallet of mnemonic if set, and writes
+// the raw transaction to out. It needs no network access.
+func (cli *CLI) SignOfflineTx(in, out, mnemonic string, hdCount int, yes bool) error {
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	otx := new(OfflineTx)
+	if err := json.Unmarshal(data, otx); err != nil {
+		return fmt.Errorf("invalid transaction file %s: %v", in, err)
+	}
+	tx, err := otx.toTransaction()
+	if err != nil {
+		return err
+	}
+	// 离线机器不连接节点, 以网络配置的 chain id 为准
+	if want := cli.Network.ChainID; want != 0 && (otx.ChainID == nil || otx.ChainID.ToInt().Cmp(new(big.Int).SetUint64(want)) != 0) {
+		return fmt.Errorf("the transaction is not for chain %d of network %s, use -network", want, cli.Network.Name)
+	}
+	if !yes && !confirm("Sign?", offlineSummary(otx, tx)) {
+		return ErrRequestDenied
+	}
+
+	var id *big.Int
+	if otx.ChainID != nil {
+		id = otx.ChainID.ToInt()
+	}
+	var signed *types.Transaction
+	if mnemonic != "" {
+		wallet, err := deriveHDWallet(mnemonic, hdCount, cli.Network.CoinType)
+		if err != nil {
+			return err
+		}
+		signed, err = wallet.SignTx(accounts.Account{Address: otx.From}, tx, id)
+		if err != nil {
+			return err
+		}
+	} else {
+		fileName, key, _, err := cli.getAccountKey(otx.From.Hex())
+		if err != nil {
+			return err
+		}
+		log.Println("your from address filename: ", fileName)
+		signed, err = hdkeystore.NewHDKeyStore(cli.DataPath, key.PrivateKey).SignTx(otx.From, tx, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteKeyFile(out, []byte(hexutil.Encode(raw)+"\n")); err != nil {
+		return err
+	}
+	log.Printf("signed transaction %s written to %s\n", signed.Hash().Hex(), out)
+	return nil
+}
+
+// txSender recovers the sender of the signed transaction tx
+func txSender(tx *types.Transaction) (common.Address, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.LatestSignerForChainID(tx.ChainId())
+	}
+	return types.Sender(signer, tx)
+}
+
+// Broadcast submits the raw signed transaction hex in the file in
+func (cli *CLI) Broadcast(in string) error {
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid raw transaction in %s: %v", in, err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return fmt.Errorf("invalid raw transaction in %s: %v", in, err)
+	}
+	from, err := txSender(tx)
+	if err != nil {
+		return err
+	}
+
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	if err := client.SendTransaction(context.Background(), tx); err != nil {
+		return err
+	}
+	cli.recordSent(from, tx)
+	log.Printf("from: %s nonce: %d broadcast %s\n", from.Hex(), tx.Nonce(), tx.Hash().Hex())
+	cli.logExplorer(tx.Hash())
+	return nil
+}
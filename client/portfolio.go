@@ -0,0 +1,269 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultPortfolioWorkers is the number of balance queries sent to the node
+// at the same time when they can't be batched
+const defaultPortfolioWorkers = 8
+
+// balanceQuery is the balance of ether (Token nil) or of a token of an account
+type balanceQuery struct {
+	Account common.Address
+	Token   *common.Address
+	Balance *big.Int
+	Err     error
+}
+
+// Portfolio is the ether and token balances of the accounts of a wallet at
+// a block
+type Portfolio struct {
+	Wallet   string             `json:"wallet"`
+	Network  string             `json:"network,omitempty"`
+	Block    uint64             `json:"block"`
+	Assets   []string           `json:"assets"`
+	Accounts []PortfolioAccount `json:"accounts"`
+	// Totals are the sums of the balances of the accounts by asset
+	Totals map[string]string `json:"totals"`
+	// Errors are the failed queries, their balances are missing
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PortfolioAccount is the balances of an account by asset in asset units,
+// ether for ETH
+type PortfolioAccount struct {
+	Address  string            `json:"address"`
+	Balances map[string]string `json:"balances"`
+}
+
+// walletAccounts lists the accounts of the wallet name, stored in key files
+// or in its database; no password is needed
+func (cli *CLI) walletAccounts(name string) ([]common.Address, error) {
+	if _, err := os.Stat(cli.walletDB(name)); os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(cli.DataPath, name)); err != nil {
+			return nil, fmt.Errorf("no wallet %s in %s", name, cli.DataPath)
+		}
+	}
+	ks, err := cli.openKeyStore(name)
+	if err != nil {
+		return nil, err
+	}
+	defer ks.Close()
+	var addrs []common.Address
+	seen := make(map[common.Address]bool)
+	for _, a := range ks.Accounts() {
+		if !seen[a.Address] {
+			seen[a.Address] = true
+			addrs = append(addrs, a.Address)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("wallet %s has no accounts", name)
+	}
+	return addrs, nil
+}
+
+// queryBalances runs the queries at block with workers concurrent calls
+func queryBalances(ctx context.Context, client *ethclient.Client, queries []*balanceQuery, block *big.Int, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan *balanceQuery)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range jobs {
+				if q.Token == nil {
+					q.Balance, q.Err = client.BalanceAt(ctx, q.Account, block)
+				} else {
+					q.Balance, q.Err = tokenBalanceAt(ctx, client, *q.Token, q.Account, block)
+				}
+			}
+		}()
+	}
+	for _, q := range queries {
+		jobs <- q
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// GetPortfolio queries the ether and registered token balances of every
+// account of the wallet name at block, the latest block if nil. All the
+// balances are read at the same block so that the totals are consistent.
+func (cli *CLI) GetPortfolio(name string, block *big.Int, workers int) (*Portfolio, error) {
+	addrs, err := cli.walletAccounts(name)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return nil, err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		block = new(big.Int).SetUint64(head)
+	} else if !block.IsUint64() || block.Uint64() > head {
+		return nil, fmt.Errorf("block %s is after the latest block %d", block, head)
+	}
+
+	metas := []*TokenMeta{nil}
+	for i := range tokens {
+		token, err := cli.tokenMeta(ctx, client, &tokens[i])
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %v", tokens[i].Key(), err)
+		}
+		metas = append(metas, token)
+	}
+
+	queries := make([][]*balanceQuery, len(addrs))
+	var all []*balanceQuery
+	for i, addr := range addrs {
+		for _, token := range metas {
+			q := &balanceQuery{Account: addr}
+			if token != nil {
+				q.Token = &token.Address
+			}
+			queries[i] = append(queries[i], q)
+			all = append(all, q)
+		}
+	}
+	if err := cli.fetchBalances(ctx, all, block, workers); err != nil {
+		return nil, err
+	}
+
+	p := &Portfolio{Wallet: name, Network: cli.Network.Name, Block: block.Uint64(), Totals: make(map[string]string)}
+	totals := make([]*big.Int, len(metas))
+	failed := make([]bool, len(metas))
+	for j, token := range metas {
+		p.Assets = append(p.Assets, assetName(token))
+		totals[j] = new(big.Int)
+	}
+	noCode := make([]bool, len(metas))
+	for i, addr := range addrs {
+		account := PortfolioAccount{Address: addr.Hex(), Balances: make(map[string]string)}
+		for j, q := range queries[i] {
+			if q.Err == bind.ErrNoCode {
+				// the token was not deployed yet at block
+				q.Balance, q.Err, noCode[j] = new(big.Int), nil, true
+			}
+			if q.Err != nil {
+				p.Errors = append(p.Errors, fmt.Sprintf("%s balance of %s: %v", p.Assets[j], addr.Hex(), q.Err))
+				failed[j] = true
+				continue
+			}
+			account.Balances[p.Assets[j]] = formatAsset(q.Balance, metas[j])
+			totals[j].Add(totals[j], q.Balance)
+		}
+		p.Accounts = append(p.Accounts, account)
+	}
+	// a total missing a balance would be wrong
+	for j := range metas {
+		if noCode[j] {
+			log.Printf("token %s has no code at block %s, its balances are 0\n", p.Assets[j], block)
+		}
+		if !failed[j] {
+			p.Totals[p.Assets[j]] = formatAsset(totals[j], metas[j])
+		}
+	}
+	for _, e := range p.Errors {
+		if strings.Contains(e, "missing trie node") {
+			log.Printf("the node doesn't keep the state of block %s, historical balances need an archive node\n", block)
+			break
+		}
+	}
+	return p, nil
+}
+
+// assetName is the column of token in a portfolio, ETH for ether
+func assetName(token *TokenMeta) string {
+	if token == nil {
+		return "ETH"
+	}
+	return token.Symbol
+}
+
+// formatAsset formats amount in ether, or in units of token
+func formatAsset(amount *big.Int, token *TokenMeta) string {
+	if token == nil {
+		return utils.FormatEther(amount)
+	}
+	return utils.FormatUnits(amount, int(token.Decimals))
+}
+
+// PrintPortfolio prints the portfolio as a table with a total row, as json
+// or as csv
+func PrintPortfolio(p *Portfolio, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(append([]string{"address"}, p.Assets...))
+		for _, a := range p.Accounts {
+			w.Write(portfolioRow(a.Address, a.Balances, p.Assets, ""))
+		}
+		w.Write(portfolioRow("total", p.Totals, p.Assets, ""))
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	default:
+		fmt.Printf("wallet: %s block: %d\n", p.Wallet, p.Block)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, strings.Join(append([]string{"ACCOUNT"}, p.Assets...), "\t")+"\t")
+		for _, a := range p.Accounts {
+			fmt.Fprintln(w, strings.Join(portfolioRow(a.Address, a.Balances, p.Assets, "ERROR"), "\t")+"\t")
+		}
+		fmt.Fprintln(w, strings.Join(portfolioRow("TOTAL", p.Totals, p.Assets, "ERROR"), "\t")+"\t")
+		w.Flush()
+	}
+	for _, e := range p.Errors {
+		log.Println("failed to query the ", e)
+	}
+	return nil
+}
+
+// portfolioRow is the balances of assets in order, missing for a failed query
+func portfolioRow(first string, balances map[string]string, assets []string, missing string) []string {
+	row := []string{first}
+	for _, asset := range assets {
+		if b, ok := balances[asset]; ok {
+			row = append(row, b)
+		} else {
+			row = append(row, missing)
+		}
+	}
+	return row
+}
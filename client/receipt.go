@@ -0,0 +1,238 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// receiptPollInterval is how often the node is asked for a receipt and new
+// blocks when it can't notify them
+const receiptPollInterval = time.Second
+
+// confirmationsFlag returns the number of confirmations to wait for from
+// the -wait and -confirmations flags, 0 not to wait
+func confirmationsFlag(wait bool, confirmations uint64) uint64 {
+	if confirmations == 0 && wait {
+		return 1
+	}
+	return confirmations
+}
+
+// errTxReplaced is returned by waitReceipt when the nonce of the
+// transaction was used by another one, e.g. a speedup or cancel
+var errTxReplaced = errors.New("replaced by another transaction with the same nonce")
+
+// waitReceipt checks at each new block until the transaction hash with the
+// nonce of from is mined and has confirmations blocks, the block of the
+// transaction included. A receipt that changes while waiting, when its
+// block is reorganized away, is waited for again. It fails with
+// errTxReplaced once the nonce is mined without the transaction, and when
+// the transaction is not confirmed after cli.WaitTimeout if set.
+func (cli *CLI) waitReceipt(ctx context.Context, client *ethclient.Client, from common.Address, nonce uint64, hash common.Hash, confirmations uint64) (*types.Receipt, error) {
+	log.Printf("waiting for %s, %d confirmations\n", hash.Hex(), confirmations)
+	ctx, cancel := context.WithCancel(ctx)
+	if cli.WaitTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cli.WaitTimeout)
+	}
+	defer cancel()
+	blocks := cli.newBlocks(ctx, receiptPollInterval)
+
+	var receipt *types.Receipt
+	for {
+		if receipt == nil {
+			r, err := client.TransactionReceipt(ctx, hash)
+			if err == ethereum.NotFound {
+				var mined uint64
+				if mined, err = client.NonceAt(ctx, from, nil); err == nil && mined > nonce {
+					// the transaction may be mined after the receipt was
+					// looked up, before the nonce
+					if r, err = client.TransactionReceipt(ctx, hash); err == ethereum.NotFound {
+						return nil, errTxReplaced
+					}
+				} else if err == nil {
+					err = ethereum.NotFound
+				}
+			}
+			if err != nil && err != ethereum.NotFound {
+				return nil, waitError(ctx, hash, err)
+			}
+			receipt = r
+		}
+		if receipt != nil {
+			head, err := client.BlockNumber(ctx)
+			if err != nil {
+				return nil, waitError(ctx, hash, err)
+			}
+			if head+1 >= receipt.BlockNumber.Uint64()+confirmations {
+				// the block may have been reorganized away while waiting
+				r, err := client.TransactionReceipt(ctx, hash)
+				if err != nil && err != ethereum.NotFound {
+					return nil, waitError(ctx, hash, err)
+				}
+				if err == nil && r.BlockHash == receipt.BlockHash {
+					return r, nil
+				}
+				log.Printf("block %d %s of %s was reorganized, waiting again\n", receipt.BlockNumber, receipt.BlockHash.Hex(), hash.Hex())
+				receipt = nil
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, waitError(ctx, hash, ctx.Err())
+		case <-blocks:
+		}
+	}
+}
+
+// waitError explains err of waitReceipt, a timeout of cli.WaitTimeout
+func waitError(ctx context.Context, hash common.Hash, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("transaction %s not confirmed in time, check it with receipt -hash or speed it up", hash.Hex())
+	}
+	return err
+}
+
+// registryTokens maps the token addresses of the token registry to the
+// tokens
+func (cli *CLI) registryTokens() map[common.Address]TokenConfig {
+	registry := make(map[common.Address]TokenConfig)
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return registry
+	}
+	for _, t := range tokens {
+		registry[common.HexToAddress(t.Addr)] = t
+	}
+	return registry
+}
+
+// receiptSummary describes the receipt of tx: status, block, gas used and
+// effective fee, and the token transfers it logged
+func (cli *CLI) receiptSummary(ctx context.Context, client *ethclient.Client, tx *types.Transaction, receipt *types.Receipt) (string, error) {
+	status := "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		status = "failed (reverted)"
+	}
+	price := tx.GasPrice()
+	if tx.Type() != types.LegacyTxType {
+		head, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+		if err != nil {
+			return "", err
+		}
+		if head.BaseFee != nil {
+			price = new(big.Int).Add(head.BaseFee, tx.EffectiveGasTipValue(head.BaseFee))
+		}
+	}
+	fee := new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed))
+
+	lines := []string{
+		fmt.Sprintf("hash:      %s", receipt.TxHash.Hex()),
+		fmt.Sprintf("status:    %s", status),
+		fmt.Sprintf("block:     %d %s", receipt.BlockNumber, receipt.BlockHash.Hex()),
+		fmt.Sprintf("gas used:  %d of %d", receipt.GasUsed, tx.Gas()),
+		fmt.Sprintf("gas price: %s gwei", utils.FormatUnits(price, 9)),
+		fmt.Sprintf("fee:       %s ether", utils.FormatEther(fee)),
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		lines = append(lines, fmt.Sprintf("contract:  %s", receipt.ContractAddress.Hex()))
+	}
+
+	registry := cli.registryTokens()
+	transfer := erc20ABI.Events["Transfer"]
+	for _, l := range receipt.Logs {
+		// Transfer(address indexed from, address indexed to, uint256 value)
+		if len(l.Topics) != 3 || l.Topics[0] != transfer.ID {
+			continue
+		}
+		values, err := transfer.Inputs.NonIndexed().Unpack(l.Data)
+		if err != nil || len(values) != 1 {
+			continue
+		}
+		amount := fmt.Sprintf("%v %s", values[0], l.Address.Hex())
+		if t, ok := registry[l.Address]; ok {
+			token := t.meta()
+			if token == nil {
+				token = &TokenMeta{Address: l.Address, Symbol: t.Key()}
+				if decimals, err := tokenDecimals(ctx, client, l.Address); err == nil {
+					token.Decimals = decimals
+				}
+			}
+			amount = token.Format(values[0].(*big.Int))
+		}
+		lines = append(lines, fmt.Sprintf("transfer:  %s from %s to %s", amount,
+			common.BytesToAddress(l.Topics[1].Bytes()).Hex(), common.BytesToAddress(l.Topics[2].Bytes()).Hex()))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// waitAndReport waits for confirmations of tx and prints its receipt
+func (cli *CLI) waitAndReport(ctx context.Context, client *ethclient.Client, tx *types.Transaction, confirmations uint64) error {
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return err
+	}
+	receipt, err := cli.waitReceipt(ctx, client, from, tx.Nonce(), tx.Hash(), confirmations)
+	if err == errTxReplaced {
+		return fmt.Errorf("transaction %s was %v, see nonces -addr %s", tx.Hash().Hex(), err, from.Hex())
+	}
+	if err != nil {
+		return err
+	}
+	summary, err := cli.receiptSummary(ctx, client, tx, receipt)
+	if err != nil {
+		return err
+	}
+	fmt.Println(summary)
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s reverted", tx.Hash().Hex())
+	}
+	return nil
+}
+
+// Receipt prints the receipt of the transaction hash, or that it is pending
+func (cli *CLI) Receipt(hash string) error {
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	tx, pending, err := client.TransactionByHash(ctx, common.HexToHash(hash))
+	if err != nil {
+		return fmt.Errorf("transaction %s: %v", hash, err)
+	}
+	if pending {
+		fmt.Printf("hash:      %s\nstatus:    pending\n", tx.Hash().Hex())
+		return nil
+	}
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return err
+	}
+	summary, err := cli.receiptSummary(ctx, client, tx, receipt)
+	if err != nil {
+		return err
+	}
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\nconfirmations: %d\n", summary, head+1-receipt.BlockNumber.Uint64())
+	if url := cli.explorerURL(tx.Hash()); url != "" {
+		fmt.Printf("explorer:  %s\n", url)
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wallet/abi"
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+token 注册表: 每个网络一个文件(cli.TokensFile, 默认 tokens.NAME.json), 记录 token 的地址,
+symbol, 以及添加时从合约读取的 name 和 decimals, 之后的命令不再查询这些不变的数据.
+
+	[{"symbol": "USDC", "addr": "0xA0b8...", "name": "USD Coin", "decimals": 6},
+	 {"symbol": "USDC", "addr": "0x2791...", "alias": "USDC.e", "name": "Bridged USDC", "decimals": 6}]
+
+命令中的 -symbol 可以是 alias, symbol 或 token 地址; symbol 相同的 token 必须用 alias 区分.
+写入时先获得 FILE.lock 再原子地替换文件, 多个 wallet 进程同时修改不会丢失数据.
+*/
+
+// tokensLockTimeout is how long a write waits for another wallet process
+// holding the registry lock
+const tokensLockTimeout = 10 * time.Second
+
+// staleTokensLock is the age of a lock file left by a killed process
+const staleTokensLock = time.Minute
+
+// Key is the name of the token in the registry, its alias if it has one
+func (t *TokenConfig) Key() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.Symbol
+}
+
+// meta is the token with its cached decimals, nil if they are not cached
+func (t *TokenConfig) meta() *TokenMeta {
+	if t.Decimals == nil {
+		return nil
+	}
+	return &TokenMeta{Address: common.HexToAddress(t.Addr), Symbol: t.Key(), Decimals: *t.Decimals}
+}
+
+// loadTokens reads the token registry file, empty if it does not exist
+func loadTokens(file string) ([]TokenConfig, error) {
+	tokens := []TokenConfig{}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token registry %s: %v", file, err)
+	}
+	return tokens, nil
+}
+
+// lockFile creates path.lock, waiting while another process holds it. The
+// returned function removes it.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	lock := path + ".lock"
+	deadline := time.Now().Add(tokensLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleTokensLock {
+			log.Printf("removing the stale lock %s\n", lock)
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another wallet process, remove %s if none is running", path, lock)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// updateTokens changes the token registry with update while holding its
+// lock, and atomically replaces the file with the result
+func (cli *CLI) updateTokens(update func([]TokenConfig) ([]TokenConfig, error)) error {
+	unlock, err := lockFile(cli.TokensFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return err
+	}
+	if tokens, err = update(tokens); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteKeyFile(cli.TokensFile, data)
+}
+
+// findToken returns the token of the registry named name: its alias or
+// symbol, or its address. A symbol shared by several tokens must be given
+// as one of their aliases.
+func findToken(tokens []TokenConfig, name string) (*TokenConfig, error) {
+	if common.IsHexAddress(name) {
+		for i := range tokens {
+			if common.HexToAddress(tokens[i].Addr) == common.HexToAddress(name) {
+				return &tokens[i], nil
+			}
+		}
+		return nil, fmt.Errorf("token %s is not registered", name)
+	}
+	for i := range tokens {
+		if tokens[i].Key() == name {
+			return &tokens[i], nil
+		}
+	}
+	var found []*TokenConfig
+	var keys []string
+	for i := range tokens {
+		if tokens[i].Symbol == name {
+			found = append(found, &tokens[i])
+			keys = append(keys, tokens[i].Key())
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("the token symbol %s is not registered", name)
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("%d tokens have the symbol %s, use one of %s", len(found), name, strings.Join(keys, ", "))
+}
+
+// findToken returns the token name of the registry of the network
+func (cli *CLI) findToken(name string) (*TokenConfig, error) {
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return nil, err
+	}
+	return findToken(tokens, name)
+}
+
+// registerToken adds the token contract at address to the registry under
+// alias, or its symbol if alias is empty, with its name and decimals. The
+// contract is checked to be an ERC-20 token first, see checkERC20; with
+// force a contract failing the check is added with a warning.
+func (cli *CLI) registerToken(address, alias string, selectors, force bool) (*TokenConfig, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid token address %s", address)
+	}
+	if common.IsHexAddress(alias) {
+		return nil, fmt.Errorf("the alias %s is an address", alias)
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	tokenAddr := common.HexToAddress(address)
+	// checkFailed returns err, or logs it with force when it is a failed check
+	checkFailed := func(err error) error {
+		if e, ok := err.(*TokenCheckError); ok && force {
+			log.Printf("WARNING: %s does not look like an ERC-20 token: %s\n", e.Token.Hex(), e.Reason)
+			return nil
+		}
+		return err
+	}
+	if err := checkERC20(ctx, client, tokenAddr, selectors); err != nil {
+		if err := checkFailed(err); err != nil {
+			return nil, err
+		}
+	}
+	symbol, err := tokenString(ctx, client, tokenAddr, "symbol")
+	if err != nil {
+		if err := checkFailed(&TokenCheckError{Token: tokenAddr, Reason: err.Error()}); err != nil {
+			return nil, err
+		}
+	}
+	if symbol == "" && alias == "" {
+		return nil, fmt.Errorf("token %s has no symbol, add it with -alias NAME", address)
+	}
+	name, err := tokenString(ctx, client, tokenAddr, "name")
+	if err != nil {
+		// name() is optional in ERC-20
+		name = ""
+	}
+	// errors of the node are returned, a bad result is registered as 0
+	// decimals only with force
+	decimals, err := tokenDecimals(ctx, client, tokenAddr)
+	if err != nil {
+		if err := checkFailed(err); err != nil {
+			return nil, err
+		}
+	}
+	token := TokenConfig{Symbol: symbol, Addr: tokenAddr.Hex(), Alias: alias, Name: name, Decimals: &decimals}
+
+	err = cli.updateTokens(func(tokens []TokenConfig) ([]TokenConfig, error) {
+		for _, t := range tokens {
+			if common.HexToAddress(t.Addr) == tokenAddr {
+				return nil, fmt.Errorf("token %s is already registered as %s", address, t.Key())
+			}
+			if t.Key() == token.Key() {
+				if alias != "" {
+					return nil, fmt.Errorf("%s is already the name of token %s", alias, t.Addr)
+				}
+				return nil, fmt.Errorf("the symbol %s is already registered for token %s, add this one with -alias NAME", symbol, t.Addr)
+			}
+		}
+		for _, w := range spoofingWarnings(token, tokens) {
+			log.Println("WARNING: possible token spoofing: ", w)
+		}
+		return append(tokens, token), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RemoveToken removes the token name of the registry
+func (cli *CLI) RemoveToken(name string) error {
+	return cli.updateTokens(func(tokens []TokenConfig) ([]TokenConfig, error) {
+		token, err := findToken(tokens, name)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tokens {
+			if &tokens[i] == token {
+				log.Printf("token %s %s removed\n", token.Key(), token.Addr)
+				return append(tokens[:i], tokens[i+1:]...), nil
+			}
+		}
+		return tokens, nil
+	})
+}
+
+// ListTokens prints the tokens of the registry of the network
+func (cli *CLI) ListTokens() error {
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		fmt.Printf("no tokens in %s\n", cli.TokensFile)
+		return nil
+	}
+	fmt.Printf("%-10s %-10s %-8s %-42s %s\n", "NAME", "SYMBOL", "DECIMALS", "ADDRESS", "TOKEN NAME")
+	for _, t := range tokens {
+		decimals := "?"
+		if t.Decimals != nil {
+			decimals = fmt.Sprint(*t.Decimals)
+		}
+		fmt.Printf("%-10s %-10s %-8s %-42s %s\n", t.Key(), t.Symbol, decimals, t.Addr, t.Name)
+	}
+	return nil
+}
+
+// TokenInfo reads the metadata of the token name from its contract: name,
+// symbol, decimals, total supply and whether the address has code. The
+// cached name and decimals of the registry are refreshed.
+func (cli *CLI) TokenInfo(name string) error {
+	token, err := cli.findToken(name)
+	if err != nil {
+		return err
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	addr := common.HexToAddress(token.Addr)
+
+	fmt.Printf("address:      %s\n", addr.Hex())
+	if token.Alias != "" {
+		fmt.Printf("alias:        %s\n", token.Alias)
+	}
+	code, err := client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return err
+	}
+	if len(code) == 0 {
+		fmt.Println("code:         none, the address is not a contract")
+		return nil
+	}
+	fmt.Printf("code:         %d bytes\n", len(code))
+
+	show := func(field, value string, err error) {
+		if err != nil {
+			value = "error: " + err.Error()
+		}
+		fmt.Printf("%-13s %s\n", field+":", value)
+	}
+	symbol, err := tokenString(ctx, client, addr, "symbol")
+	show("symbol", symbol, err)
+	tokenName, nameErr := tokenString(ctx, client, addr, "name")
+	show("name", tokenName, nameErr)
+	decimals, decimalsErr := tokenDecimals(ctx, client, addr)
+	show("decimals", fmt.Sprint(decimals), decimalsErr)
+	if decimalsErr == nil {
+		meta := &TokenMeta{Address: addr, Symbol: token.Key(), Decimals: decimals}
+		erc20, err := abi.NewERC20Caller(addr, client)
+		if err != nil {
+			return err
+		}
+		supply, err := erc20.TotalSupply(&bind.CallOpts{Context: ctx})
+		if err == nil {
+			show("total supply", fmt.Sprintf("%s (%s base units)", meta.Format(supply), supply), nil)
+		} else {
+			show("total supply", "", err)
+		}
+	}
+
+	if decimalsErr != nil || (token.Decimals != nil && *token.Decimals == decimals && token.Name == tokenName) {
+		return nil
+	}
+	return cli.updateTokens(func(tokens []TokenConfig) ([]TokenConfig, error) {
+		for i := range tokens {
+			if common.HexToAddress(tokens[i].Addr) == addr {
+				tokens[i].Decimals = &decimals
+				if nameErr == nil {
+					tokens[i].Name = tokenName
+				}
+			}
+		}
+		return tokens, nil
+	})
+}
+
+// cacheDecimals stores the decimals read from the contract of a token
+// registered before they were cached
+func (cli *CLI) cacheDecimals(addr common.Address, decimals uint8) {
+	err := cli.updateTokens(func(tokens []TokenConfig) ([]TokenConfig, error) {
+		for i := range tokens {
+			if common.HexToAddress(tokens[i].Addr) == addr && tokens[i].Decimals == nil {
+				tokens[i].Decimals = &decimals
+			}
+		}
+		return tokens, nil
+	})
+	if err != nil {
+		log.Println("failed to cache the token decimals: ", err)
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// replacePriceBump is the minimum fee increase in percent of a transaction
+// replacing a pending one, the default of the geth transaction pool
+const replacePriceBump = 10
+
+// bumpFee returns the lowest fee the node accepts to replace a transaction
+// paying old: replacePriceBump percent more, and at least one wei more
+func bumpFee(old *big.Int) *big.Int {
+	fee := new(big.Int).Mul(old, big.NewInt(100+replacePriceBump))
+	fee.Div(fee, big.NewInt(100))
+	if fee.Cmp(old) <= 0 {
+		fee.Add(old, big.NewInt(1))
+	}
+	return fee
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// pendingTx returns the transaction currently sent with the nonce of the
+// transaction hash and its sender. Transactions of the nonce manager are
+// found even when they were replaced or dropped, others must be pending
+// in the node.
+func (cli *CLI) pendingTx(ctx context.Context, client *ethclient.Client, hash common.Hash) (*types.Transaction, common.Address, error) {
+	nm, err := OpenNonceManager(cli.nonceDB())
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	from, rec, err := nm.Find(hash)
+	nm.Close()
+	if err == nil && len(rec.Raw) > 0 {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(rec.Raw); err != nil {
+			return nil, common.Address{}, err
+		}
+		if rec.Hash != hash {
+			log.Printf("transaction %s was replaced by %s\n", hash.Hex(), rec.Hash.Hex())
+		}
+		return tx, from, nil
+	}
+
+	tx, pending, err := client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("transaction %s: %v", hash.Hex(), err)
+	}
+	if !pending {
+		return nil, common.Address{}, fmt.Errorf("transaction %s is already mined", hash.Hex())
+	}
+	from, err = txSender(tx)
+	return tx, from, err
+}
+
+// ReplaceTx sends a transaction with the nonce of the pending transaction
+// hash at a higher fee: the same payload to speed it up, or a zero value
+// transfer to its sender to cancel it. The fees are the network ones for
+// opts.Speed, raised to the replacement minimum of the node; opts.GasPrice
+// overrides the gas price or max fee and must reach that minimum.
+func (cli *CLI) ReplaceTx(hash string, cancel bool, opts TxOptions, yes bool) error {
+	rclient, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	client := ethclient.NewClient(rclient)
+	ctx := context.Background()
+
+	old, from, err := cli.pendingTx(ctx, client, common.HexToHash(hash))
+	if err != nil {
+		return err
+	}
+	confirmed, err := client.NonceAt(ctx, from, nil)
+	if err != nil {
+		return err
+	}
+	if old.Nonce() < confirmed {
+		return fmt.Errorf("nonce %d of %s is already mined", old.Nonce(), from.Hex())
+	}
+
+	msg := ethereum.CallMsg{From: from, To: old.To(), Value: old.Value(), Data: old.Data()}
+	opts.Gas = old.Gas()
+	if cancel {
+		msg = ethereum.CallMsg{From: from, To: &from, Value: new(big.Int)}
+		opts.Gas = 21000
+	} else if err := preflightReplacement(ctx, rclient, msg, opts.Force); err != nil {
+		return err
+	}
+	if err := cli.checkQuorum(ctx, from, nil); err != nil {
+		return err
+	}
+	// keep the type of the replaced transaction
+	opts.Legacy = old.Type() == types.LegacyTxType
+	given := opts.GasPrice
+	if err := fillFees(ctx, rclient, &opts); err != nil {
+		return err
+	}
+
+	// a legacy gas price is both the fee cap and the tip
+	minFee, minTip := bumpFee(old.GasFeeCap()), bumpFee(old.GasTipCap())
+	if given != nil && given.Cmp(minFee) < 0 {
+		return fmt.Errorf("gas price %s gwei is below the replacement minimum %s gwei", utils.FormatUnits(given, 9), utils.FormatUnits(minFee, 9))
+	}
+	opts.GasPrice = maxBig(opts.GasPrice, minFee)
+	if !opts.Legacy {
+		opts.GasTipCap = maxBig(opts.GasTipCap, minTip)
+		if opts.GasTipCap.Cmp(opts.GasPrice) > 0 {
+			if given != nil {
+				return fmt.Errorf("max fee %s gwei is below the replacement priority fee %s gwei", utils.FormatUnits(given, 9), utils.FormatUnits(opts.GasTipCap, 9))
+			}
+			opts.GasPrice = new(big.Int).Set(opts.GasTipCap)
+		}
+	}
+
+	id, err := cli.chainID(ctx, rclient)
+	if err != nil {
+		return err
+	}
+	tx := newTx(old.Nonce(), msg, opts, id)
+	if err := checkFunds(ctx, rclient, from, tx); err != nil {
+		return err
+	}
+	action := "speedup"
+	if cancel {
+		action = "cancel"
+	}
+	summary := transferSummary(from.Hex(), tx) + dataSummary(tx, "", nil) +
+		fmt.Sprintf("\n%-10s %s nonce %d", action+":", old.Hash().Hex(), old.Nonce())
+	if !yes && !confirm("Send?", summary) {
+		return ErrRequestDenied
+	}
+
+	stx, err := cli.signTx(from.Hex(), tx, id)
+	if err != nil {
+		return err
+	}
+	if err := client.SendTransaction(ctx, stx); err != nil {
+		return err
+	}
+	cli.recordSent(from, stx)
+	log.Printf("%s of %s nonce %d sent: %s\n", action, old.Hash().Hex(), old.Nonce(), stx.Hash().Hex())
+	cli.logExplorer(stx.Hash())
+	return nil
+}
@@ -0,0 +1,489 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"wallet/hdwallet"
+	"wallet/keystorecode"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrRequestDenied is returned when a signing request was not approved
+var ErrRequestDenied = errors.New("request denied")
+
+// SendTxArgs is the transaction passed to account_signTransaction, with
+// maxFeePerGas instead of gasPrice for dynamic fee transactions
+type SendTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	Value                hexutil.Big     `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 *hexutil.Bytes  `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId,omitempty"`
+}
+
+func (args *SendTxArgs) toTransaction() (*types.Transaction, error) {
+	var data []byte
+	if args.Data != nil {
+		data = *args.Data
+	}
+	if args.MaxFeePerGas != nil {
+		if args.ChainID == nil || args.MaxPriorityFeePerGas == nil {
+			return nil, errors.New("dynamic fee transactions need chainId and maxPriorityFeePerGas")
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   (*big.Int)(args.ChainID),
+			Nonce:     uint64(args.Nonce),
+			To:        args.To,
+			Value:     (*big.Int)(&args.Value),
+			Gas:       uint64(args.Gas),
+			GasFeeCap: (*big.Int)(args.MaxFeePerGas),
+			GasTipCap: (*big.Int)(args.MaxPriorityFeePerGas),
+			Data:      data,
+		}), nil
+	}
+	if args.GasPrice == nil {
+		return nil, errors.New("missing gasPrice or maxFeePerGas")
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    uint64(args.Nonce),
+		To:       args.To,
+		Value:    (*big.Int)(&args.Value),
+		Gas:      uint64(args.Gas),
+		GasPrice: (*big.Int)(args.GasPrice),
+		Data:     data,
+	}), nil
+}
+
+// txArgs is the inverse of toTransaction
+func txArgs(from common.Address, tx *types.Transaction, chainID *big.Int) SendTxArgs {
+	data := hexutil.Bytes(tx.Data())
+	args := SendTxArgs{
+		From:  from,
+		To:    tx.To(),
+		Gas:   hexutil.Uint64(tx.Gas()),
+		Value: hexutil.Big(*tx.Value()),
+		Nonce: hexutil.Uint64(tx.Nonce()),
+		Data:  &data,
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	}
+	if chainID != nil {
+		args.ChainID = (*hexutil.Big)(chainID)
+	}
+	return args
+}
+
+// txFees describes the gas and fees of tx
+func txFees(tx *types.Transaction) string {
+	if tx.Type() == types.DynamicFeeTxType {
+		return fmt.Sprintf("gas: %d maxFeePerGas: %s maxPriorityFeePerGas: %s", tx.Gas(), tx.GasFeeCap(), tx.GasTipCap())
+	}
+	return fmt.Sprintf("gas: %d gasPrice: %s", tx.Gas(), tx.GasPrice())
+}
+
+// SignTxResult is the result of account_signTransaction
+type SignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// signRequest describes a request waiting for approval
+type signRequest struct {
+	Method string
+	From   common.Address
+	To     *common.Address
+	Value  *big.Int
+	Data   []byte
+	Detail string
+}
+
+func (req *signRequest) String() string {
+	s := fmt.Sprintf("%s from: %s", req.Method, req.From.Hex())
+	if req.To != nil {
+		s += " to: " + req.To.Hex()
+	}
+	if req.Value != nil {
+		s += " value: " + req.Value.String()
+	}
+	if req.Detail != "" {
+		s += "\n" + req.Detail
+	}
+	return s
+}
+
+// signerRule approves or rejects the requests it matches, empty fields
+// match everything. An approve rule only matches transactions with
+// calldata when AllowData is set: maxValue caps the ether value only, a
+// rule for a token contract would approve transfers of any amount.
+type signerRule struct {
+	Method    string `json:"method"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	MaxValue  string `json:"maxValue,omitempty"`
+	AllowData bool   `json:"allowData,omitempty"`
+	Action    string `json:"action"`
+}
+
+// signerRules is the content of the rule file, the first matching rule
+// decides, Default ("prompt" or "reject") applies when none matches
+type signerRules struct {
+	Rules   []signerRule `json:"rules"`
+	Default string       `json:"default"`
+}
+
+func (rule *signerRule) match(req *signRequest) bool {
+	if rule.Method != "" && rule.Method != req.Method {
+		return false
+	}
+	if rule.From != "" && common.HexToAddress(rule.From) != req.From {
+		return false
+	}
+	if rule.To != "" && (req.To == nil || common.HexToAddress(rule.To) != *req.To) {
+		return false
+	}
+	if rule.MaxValue != "" {
+		max, ok := new(big.Int).SetString(rule.MaxValue, 10)
+		if !ok || req.Value == nil || req.Value.Cmp(max) > 0 {
+			return false
+		}
+	}
+	if rule.Action == "approve" && len(req.Data) > 0 && !rule.AllowData {
+		return false
+	}
+	return true
+}
+
+// approver decides on signing requests by rules and otherwise asks on the
+// terminal the signer runs on
+type approver struct {
+	rules *signerRules
+	mu    sync.Mutex
+	in    *bufio.Reader
+}
+
+func newApprover(rulesFile string) (*approver, error) {
+	ap := &approver{in: bufio.NewReader(os.Stdin)}
+	if rulesFile == "" {
+		return ap, nil
+	}
+	data, err := ioutil.ReadFile(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	rules := new(signerRules)
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("invalid rule file: %v", err)
+	}
+	for _, rule := range rules.Rules {
+		if rule.Action != "approve" && rule.Action != "reject" {
+			return nil, fmt.Errorf("invalid rule action: %s", rule.Action)
+		}
+	}
+	if rules.Default == "" {
+		rules.Default = "prompt"
+	}
+	if rules.Default != "prompt" && rules.Default != "reject" {
+		return nil, fmt.Errorf("invalid rule default: %s", rules.Default)
+	}
+	ap.rules = rules
+	return ap, nil
+}
+
+func (ap *approver) approve(req *signRequest) error {
+	// no approver, the agent: it signs only the transactions of the CLI
+	if ap == nil {
+		return nil
+	}
+	if ap.rules != nil {
+		for _, rule := range ap.rules.Rules {
+			if rule.match(req) {
+				log.Printf("%s: %s by rule\n", req.Method, rule.Action)
+				if rule.Action == "approve" {
+					return nil
+				}
+				return ErrRequestDenied
+			}
+		}
+		if ap.rules.Default == "reject" {
+			log.Printf("%s: rejected by default rule\n", req.Method)
+			return ErrRequestDenied
+		}
+	}
+
+	// one prompt at a time on the terminal
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	fmt.Printf("\n-------- signing request --------\n%s\nApprove? [y/N] ", req)
+	answer, err := ap.in.ReadString('\n')
+	if err != nil {
+		return ErrRequestDenied
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return ErrRequestDenied
+	}
+	return nil
+}
+
+// SignerAPI is served in the "account" namespace by the signer daemon
+type SignerAPI struct {
+	ks       *keystorecode.KeyStore
+	hd       *hdwallet.Wallet
+	approver *approver
+}
+
+// List returns the addresses of all accounts held by the signer
+func (api *SignerAPI) List(ctx context.Context) ([]common.Address, error) {
+	addrs := []common.Address{}
+	if api.ks != nil {
+		for _, a := range api.ks.Accounts() {
+			addrs = append(addrs, a.Address)
+		}
+	}
+	if api.hd != nil {
+		for _, a := range api.hd.Accounts() {
+			addrs = append(addrs, a.Address)
+		}
+	}
+	if err := api.approver.approve(&signRequest{Method: "account_list"}); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// SignTransaction signs the transaction after it was approved and returns
+// the raw transaction ready for eth_sendRawTransaction
+func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs) (*SignTxResult, error) {
+	tx, err := args.toTransaction()
+	if err != nil {
+		return nil, err
+	}
+	req := &signRequest{
+		Method: "account_signTransaction",
+		From:   args.From,
+		To:     args.To,
+		Value:  tx.Value(),
+		Data:   tx.Data(),
+		Detail: fmt.Sprintf("nonce: %d %s data: %s", tx.Nonce(), txFees(tx), hexutil.Encode(tx.Data())),
+	}
+	if err := api.approver.approve(req); err != nil {
+		return nil, err
+	}
+
+	var chainID *big.Int
+	if args.ChainID != nil {
+		chainID = (*big.Int)(args.ChainID)
+	}
+	account := accounts.Account{Address: args.From}
+	var signed *types.Transaction
+	switch {
+	case api.ks != nil && api.ks.HasAddress(args.From):
+		signed, err = api.ks.SignTx(account, tx, chainID)
+	case api.hd != nil && api.hd.Contains(account):
+		signed, err = api.hd.SignTx(account, tx, chainID)
+	default:
+		return nil, accounts.ErrUnknownAccount
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignTxResult{Raw: raw, Tx: signed}, nil
+}
+
+// SignData signs text/plain data as an EIP-191 personal message
+func (api *SignerAPI) SignData(ctx context.Context, contentType string, addr common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != "text/plain" {
+		return nil, fmt.Errorf("content type not supported: %s", contentType)
+	}
+	req := &signRequest{Method: "account_signData", From: addr, Detail: fmt.Sprintf("message: %q", string(data))}
+	if err := api.approver.approve(req); err != nil {
+		return nil, err
+	}
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(data), data)
+	return api.signHash(addr, crypto.Keccak256([]byte(msg)))
+}
+
+// SignTypedData signs EIP-712 structured data
+func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.Address, typedData TypedData) (hexutil.Bytes, error) {
+	hash, err := typedData.Hash()
+	if err != nil {
+		return nil, err
+	}
+	message, _ := json.MarshalIndent(typedData.Message, "", "  ")
+	req := &signRequest{
+		Method: "account_signTypedData",
+		From:   addr,
+		Detail: fmt.Sprintf("primaryType: %s domain: %v\nmessage: %s", typedData.PrimaryType, typedData.Domain, message),
+	}
+	if err := api.approver.approve(req); err != nil {
+		return nil, err
+	}
+	return api.signHash(addr, hash)
+}
+
+// signHash signs the hash and moves V to 27/28 as expected by ecrecover
+func (api *SignerAPI) signHash(addr common.Address, hash []byte) (hexutil.Bytes, error) {
+	account := accounts.Account{Address: addr}
+	var (
+		sig []byte
+		err error
+	)
+	switch {
+	case api.ks != nil && api.ks.HasAddress(addr):
+		sig, err = api.ks.SignHash(account, hash)
+	case api.hd != nil && api.hd.Contains(account):
+		sig, err = api.hd.SignHash(account, hash)
+	default:
+		return nil, accounts.ErrUnknownAccount
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// RunSigner unlocks the wallet's accounts (and the hd accounts of mnemonic)
+// and serves the account_* api on a unix socket or a localhost http address
+func (cli *CLI) RunSigner(name, pass, mnemonic string, hdCount int, socket, httpAddr, rulesFile string) error {
+	ap, err := newApprover(rulesFile)
+	if err != nil {
+		return err
+	}
+	api := &SignerAPI{approver: ap}
+
+	if name != "" {
+		api.ks, err = cli.openKeyStore(name)
+		if err != nil {
+			return err
+		}
+		for _, a := range api.ks.Accounts() {
+			if err := api.ks.Unlock(a, pass); err != nil {
+				return fmt.Errorf("failed to unlock %s: %v", a.Address.Hex(), err)
+			}
+		}
+		log.Printf("unlocked %d accounts of wallet %s\n", len(api.ks.Accounts()), name)
+	}
+	if mnemonic != "" {
+		api.hd, err = deriveHDWallet(mnemonic, hdCount, cli.Network.CoinType)
+		if err != nil {
+			return err
+		}
+		log.Printf("derived %d hd accounts\n", hdCount)
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", api); err != nil {
+		return err
+	}
+	if httpAddr != "" {
+		host, _, err := net.SplitHostPort(httpAddr)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("signer only listens on localhost, not %s", httpAddr)
+		}
+		log.Println("signer listening on http://" + httpAddr)
+		return http.ListenAndServe(httpAddr, localhostOnly(server))
+	}
+
+	listener, err := listenUnix(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+	log.Println("signer listening on", socket)
+	return server.ServeListener(listener)
+}
+
+// localhostOnly rejects the requests whose Host header is not the local
+// host, like the virtual hosts of geth: a web page whose domain resolves to
+// 127.0.0.1 (DNS rebinding) can't reach the signer
+func localhostOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			http.Error(w, "invalid host specified", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// remoteSignTx asks the signer daemon or agent at url to sign tx
+func remoteSignTx(url string, from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var result SignTxResult
+	if err := client.Call(&result, "account_signTransaction", txArgs(from, tx, chainID)); err != nil {
+		return nil, err
+	}
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(result.Raw); err != nil {
+		return nil, err
+	}
+	// never trust the daemon blindly
+	var txSigner types.Signer = types.HomesteadSigner{}
+	if chainID != nil {
+		txSigner = types.LatestSignerForChainID(chainID)
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, err
+	}
+	if sender != from {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", from.Hex(), sender.Hex())
+	}
+	if signed.Type() != tx.Type() || signed.Nonce() != tx.Nonce() || signed.Gas() != tx.Gas() ||
+		signed.GasFeeCap().Cmp(tx.GasFeeCap()) != 0 || signed.GasTipCap().Cmp(tx.GasTipCap()) != 0 ||
+		signed.Value().Cmp(tx.Value()) != 0 || !bytes.Equal(signed.Data(), tx.Data()) || !sameRecipient(signed.To(), tx.To()) {
+		return nil, fmt.Errorf("signer returned a different transaction")
+	}
+	return signed, nil
+}
+
+func sameRecipient(a, b *common.Address) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	ethabi "github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// panicSelector is the selector of the Panic(uint256) error of solidity >= 0.8
+var panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+// panicReasons explains the codes of Panic(uint256)
+var panicReasons = map[uint64]string{
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array",
+	0x31: "pop on an empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call of an uninitialized function",
+}
+
+// SimulationError is the failure of an outgoing transaction simulated with
+// eth_call, it is sent anyway with -force
+type SimulationError struct {
+	Reason string
+}
+
+func (e *SimulationError) Error() string {
+	return "the transaction would fail: " + e.Reason + ", use -force to send it anyway"
+}
+
+// revertReason decodes the revert data of a call: Error(string),
+// Panic(uint256) or a custom error selector
+func revertReason(data []byte) string {
+	if len(data) == 0 {
+		return "reverted without reason"
+	}
+	if reason, err := ethabi.UnpackRevert(data); err == nil {
+		return "reverted: " + reason
+	}
+	if len(data) == 36 && bytes.Equal(data[:4], panicSelector) {
+		code := new(big.Int).SetBytes(data[4:])
+		if reason, ok := panicReasons[code.Uint64()]; ok && code.IsUint64() {
+			return fmt.Sprintf("panic 0x%x: %s", code, reason)
+		}
+		return fmt.Sprintf("panic 0x%x", code)
+	}
+	if len(data) >= 4 {
+		return fmt.Sprintf("reverted with custom error %s", hexutil.Encode(data[:4]))
+	}
+	return "reverted: " + hexutil.Encode(data)
+}
+
+// returnsFalse reports whether the call data of an ERC-20 transfer or
+// transferFrom returned false: such tokens don't revert on insufficient
+// balance or allowance, nothing is moved but the transaction succeeds
+func returnsFalse(data, out []byte) bool {
+	if len(data) < 4 || len(out) != 32 {
+		return false
+	}
+	method, err := erc20ABI.MethodById(data[:4])
+	if err != nil || (method.Name != "transfer" && method.Name != "transferFrom") {
+		return false
+	}
+	return new(big.Int).SetBytes(out).Sign() == 0
+}
+
+// simulate runs msg with eth_call on the pending state, or the latest block
+// when pending is not set, before it is signed and returns a
+// SimulationError explaining why it would fail
+func simulate(ctx context.Context, rc *rpc.Client, msg ethereum.CallMsg, pending bool) error {
+	var out []byte
+	var err error
+	if pending {
+		out, err = ethclient.NewClient(rc).PendingCallContract(ctx, msg)
+	} else {
+		out, err = ethclient.NewClient(rc).CallContract(ctx, msg, nil)
+	}
+	if err != nil {
+		dataErr, ok := err.(rpc.DataError)
+		if !ok {
+			// execution errors without data, e.g. insufficient funds
+			if _, isRPC := err.(rpc.Error); isRPC {
+				return &SimulationError{Reason: err.Error()}
+			}
+			return err
+		}
+		hexData, _ := dataErr.ErrorData().(string)
+		data, decodeErr := hexutil.Decode(hexData)
+		if decodeErr != nil {
+			return &SimulationError{Reason: err.Error()}
+		}
+		return &SimulationError{Reason: revertReason(data)}
+	}
+	if returnsFalse(msg.Data, out) {
+		method, _ := erc20ABI.MethodById(msg.Data[:4])
+		return &SimulationError{Reason: fmt.Sprintf("the token %s returned false, e.g. insufficient balance or allowance, no tokens would move", method.Name)}
+	}
+	return nil
+}
+
+// preflight simulates msg, with force a failure is only logged and the
+// transaction is sent anyway
+func preflight(ctx context.Context, rc *rpc.Client, msg ethereum.CallMsg, force bool) error {
+	return forceSimulation(simulate(ctx, rc, msg, true), force)
+}
+
+// preflightReplacement simulates the payload of a pending transaction on
+// the latest block, the pending state already contains the transaction
+// being replaced, e.g. a transfer of the whole balance would fail there
+func preflightReplacement(ctx context.Context, rc *rpc.Client, msg ethereum.CallMsg, force bool) error {
+	return forceSimulation(simulate(ctx, rc, msg, false), force)
+}
+
+func forceSimulation(err error, force bool) error {
+	if simErr, ok := err.(*SimulationError); ok && force {
+		log.Printf("warning: the transaction would fail: %s, sending it anyway (set -gas if the estimation fails)\n", simErr.Reason)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"wallet/abi"
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TokenMeta is a token of the registry with the metadata read from its
+// contract
+type TokenMeta struct {
+	Address common.Address
+	// Symbol is the name of the token in the registry, its alias if it has one
+	Symbol   string
+	Decimals uint8
+}
+
+// Format formats an amount of base units of the token in token units
+func (t *TokenMeta) Format(amount *big.Int) string {
+	return utils.FormatUnits(amount, int(t.Decimals)) + " " + t.Symbol
+}
+
+// Parse parses an amount in token units, e.g. 12.5, into base units
+func (t *TokenMeta) Parse(s string) (*big.Int, error) {
+	amount, err := utils.ParseUnits(s, int(t.Decimals))
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s has %d decimals)", err, t.Symbol, t.Decimals)
+	}
+	return amount, nil
+}
+
+// tokenString calls the method name or symbol of token. Old tokens such as
+// MKR return a bytes32 instead of a string, it is decoded as well.
+func tokenString(ctx context.Context, caller bind.ContractCaller, token common.Address, method string) (string, error) {
+	data, err := erc20ABI.Pack(method)
+	if err != nil {
+		return "", err
+	}
+	out, err := caller.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return "", err
+	}
+	if values, err := erc20ABI.Unpack(method, out); err == nil && len(values) == 1 {
+		return values[0].(string), nil
+	}
+	if len(out) == 32 {
+		return string(bytes.TrimRight(out, "\x00")), nil
+	}
+	return "", fmt.Errorf("%s() of %s returned %s, not a string", method, token.Hex(), hexutil.Encode(out))
+}
+
+// isRevert tells if the error of an eth_call is a revert of the contract,
+// with or without revert data, and not a failure of the node
+func isRevert(err error) bool {
+	if _, ok := err.(rpc.Error); !ok {
+		return false
+	}
+	if dataErr, ok := err.(rpc.DataError); ok && dataErr.ErrorData() != nil {
+		return true
+	}
+	return strings.Contains(err.Error(), "execution reverted")
+}
+
+// tokenDecimals calls decimals() of token. The method is optional in
+// ERC-20, a token without it has 0 decimals: amounts are base units. Only a
+// revert or an empty result means there is no decimals(), other errors are
+// returned and nothing must be cached from them.
+func tokenDecimals(ctx context.Context, caller bind.ContractCaller, token common.Address) (uint8, error) {
+	data, err := erc20ABI.Pack("decimals")
+	if err != nil {
+		return 0, err
+	}
+	out, err := caller.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if isRevert(err) || (err == nil && len(out) == 0) {
+		log.Printf("token %s has no decimals(), amounts are base units\n", token.Hex())
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	decimals := new(big.Int).SetBytes(out)
+	if len(out) != 32 || !decimals.IsUint64() || decimals.Uint64() > 255 {
+		return 0, &TokenCheckError{Token: token, Reason: "decimals() returned " + hexutil.Encode(out)}
+	}
+	return uint8(decimals.Uint64()), nil
+}
+
+// lookupToken returns the token symbol of the registry and its decimals,
+// read from the contract and cached if the registry doesn't have them yet
+func (cli *CLI) lookupToken(ctx context.Context, symbol string) (*TokenMeta, error) {
+	t, err := cli.findToken(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if token := t.meta(); token != nil {
+		return token, nil
+	}
+	client, err := cli.ethClient()
+	if err != nil {
+		return nil, err
+	}
+	return cli.tokenMeta(ctx, client, t)
+}
+
+// tokenMeta returns the token t of the registry with its decimals, read
+// from the contract and cached if the registry doesn't have them yet
+func (cli *CLI) tokenMeta(ctx context.Context, caller bind.ContractCaller, t *TokenConfig) (*TokenMeta, error) {
+	if token := t.meta(); token != nil {
+		return token, nil
+	}
+	token := &TokenMeta{Address: common.HexToAddress(t.Addr), Symbol: t.Key()}
+	var err error
+	if token.Decimals, err = tokenDecimals(ctx, caller, token.Address); err != nil {
+		return nil, err
+	}
+	cli.cacheDecimals(token.Address, token.Decimals)
+	return token, nil
+}
+
+// tokenBalance returns the balance of account of token with the generic
+// ERC-20 binding
+func tokenBalance(ctx context.Context, caller bind.ContractCaller, token, account common.Address) (*big.Int, error) {
+	return tokenBalanceAt(ctx, caller, token, account, nil)
+}
+
+// tokenBalanceAt returns the balance of account of token at block, the
+// latest block if nil
+func tokenBalanceAt(ctx context.Context, caller bind.ContractCaller, token, account common.Address, block *big.Int) (*big.Int, error) {
+	erc20, err := abi.NewERC20Caller(token, caller)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.BalanceOf(&bind.CallOpts{Context: ctx, BlockNumber: block}, account)
+}
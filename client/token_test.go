@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// fakeCaller answers every eth_call with out and err
+type fakeCaller struct {
+	out []byte
+	err error
+}
+
+func (c fakeCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0}, nil
+}
+
+func (c fakeCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.out, c.err
+}
+
+// fakeRPCError is an error response of the node, with data if set
+type fakeRPCError struct {
+	msg  string
+	data interface{}
+}
+
+func (e fakeRPCError) Error() string          { return e.msg }
+func (e fakeRPCError) ErrorCode() int         { return 3 }
+func (e fakeRPCError) ErrorData() interface{} { return e.data }
+
+func TestTokenDecimals(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  fakeCaller
+		want    uint8
+		wantErr bool
+	}{
+		{"decimals", fakeCaller{out: math.U256Bytes(big.NewInt(6))}, 6, false},
+		{"empty result", fakeCaller{out: []byte{}}, 0, false},
+		{"revert", fakeCaller{err: fakeRPCError{msg: "execution reverted"}}, 0, false},
+		{"revert data", fakeCaller{err: fakeRPCError{msg: "reverted", data: "0x08c379a0"}}, 0, false},
+		{"node error", fakeCaller{err: fakeRPCError{msg: "header not found"}}, 0, true},
+		{"transport error", fakeCaller{err: errors.New("502 Bad Gateway")}, 0, true},
+		{"bad result", fakeCaller{out: []byte{1, 2}}, 0, true},
+		{"too large", fakeCaller{out: math.U256Bytes(big.NewInt(256))}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tokenDecimals(context.Background(), tt.caller, common.Address{1})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d decimals, want %d", tt.name, got, tt.want)
+		}
+	}
+}
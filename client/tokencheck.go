@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"wallet/abi"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// erc20Functions are the functions every ERC-20 token implements, their
+// selectors are looked for in the bytecode of a token with -selectors
+var erc20Functions = []string{"totalSupply", "balanceOf", "transfer", "transferFrom", "approve", "allowance"}
+
+// push4 is the opcode solidity dispatches function selectors with
+const push4 = 0x63
+
+// TokenCheckError is a contract that doesn't behave as an ERC-20 token, it
+// is added anyway with -force
+type TokenCheckError struct {
+	Token  common.Address
+	Reason string
+}
+
+func (e *TokenCheckError) Error() string {
+	return fmt.Sprintf("%s does not look like an ERC-20 token: %s, use -force to add it anyway", e.Token.Hex(), e.Reason)
+}
+
+// checkERC20 verifies that token is a contract answering the ERC-20 calls
+// totalSupply and balanceOf with one uint256. With selectors its bytecode
+// must also dispatch every function of erc20Functions; a proxy forwarding
+// the calls to another contract does not, it fails this check. An address
+// without code is never a token.
+func checkERC20(ctx context.Context, client *ethclient.Client, token common.Address, selectors bool) error {
+	code, err := client.CodeAt(ctx, token, nil)
+	if err != nil {
+		return err
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("%s is not a contract (no code), it can't be a token", token.Hex())
+	}
+
+	erc20, err := abi.NewERC20Caller(token, client)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	if _, err := erc20.TotalSupply(opts); err != nil {
+		return probeError(token, "totalSupply()", err)
+	}
+	// the balance of the token contract itself, any address answers
+	if _, err := erc20.BalanceOf(opts, token); err != nil {
+		return probeError(token, "balanceOf(address)", err)
+	}
+
+	if selectors {
+		var missing []string
+		for _, name := range erc20Functions {
+			id := erc20ABI.Methods[name].ID
+			if !bytes.Contains(code, append([]byte{push4}, id...)) {
+				missing = append(missing, erc20ABI.Methods[name].Sig)
+			}
+		}
+		if len(missing) > 0 {
+			return &TokenCheckError{Token: token, Reason: "the bytecode has no selector of " + strings.Join(missing, ", ")}
+		}
+	}
+	return nil
+}
+
+// probeError is the failure of the ERC-20 call method of token: a revert or
+// an unexpected result is a TokenCheckError, other errors are of the node
+func probeError(token common.Address, method string, err error) error {
+	if _, reverted := err.(rpc.Error); reverted {
+		return &TokenCheckError{Token: token, Reason: method + " " + err.Error()}
+	}
+	if err == bind.ErrNoCode || strings.Contains(err.Error(), "abi:") {
+		return &TokenCheckError{Token: token, Reason: method + " returned an unexpected result: " + err.Error()}
+	}
+	return err
+}
+
+// spoofingWarnings compares a new token with the registered ones: a token
+// reusing the symbol or the name of another one, or with characters that
+// imitate ascii letters, may pretend to be it
+func spoofingWarnings(token TokenConfig, tokens []TokenConfig) []string {
+	var warnings []string
+	for _, t := range tokens {
+		if common.HexToAddress(t.Addr) == common.HexToAddress(token.Addr) {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(t.Symbol), strings.TrimSpace(token.Symbol)) {
+			warnings = append(warnings, fmt.Sprintf("the symbol %s is also the symbol of %s %s", token.Symbol, t.Key(), t.Addr))
+		}
+		if token.Name != "" && strings.EqualFold(strings.TrimSpace(t.Name), strings.TrimSpace(token.Name)) {
+			warnings = append(warnings, fmt.Sprintf("the name %q is also the name of %s %s", token.Name, t.Key(), t.Addr))
+		}
+	}
+	for _, field := range [][2]string{{"symbol", token.Symbol}, {"name", token.Name}} {
+		for _, r := range field[1] {
+			if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+				warnings = append(warnings, fmt.Sprintf("the %s %q has the non-ascii character %U, it may imitate another token", field[0], field[1], r))
+				break
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// dialTimeout bounds connecting to a websocket or ipc node, http connects
+// on the first request
+const dialTimeout = 10 * time.Second
+
+// node transports of the network url
+const (
+	TransportHTTP = "http"
+	TransportWS   = "ws"
+	TransportIPC  = "ipc"
+)
+
+// nodeTransport returns the transport of the node url: http:// and
+// https://, ws:// and wss://, or the path of an ipc socket (a named pipe
+// \\.\pipe\geth.ipc on windows)
+func nodeTransport(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", fmt.Errorf("invalid node url %s: %v", rawurl, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return TransportHTTP, nil
+	case "ws", "wss":
+		return TransportWS, nil
+	case "":
+		if rawurl == "" {
+			return "", fmt.Errorf("no node url")
+		}
+		return TransportIPC, nil
+	}
+	return "", fmt.Errorf("unsupported node url %s: use http(s)://, ws(s):// or the path of an ipc socket", rawurl)
+}
+
+// rpcClient returns the connection to the node of the network. It is
+// dialled on first use and shared by the whole command, Close closes it.
+func (cli *CLI) rpcClient() (*rpc.Client, error) {
+	if cli.rc != nil {
+		return cli.rc, nil
+	}
+	if len(cli.Network.Endpoints) > 1 {
+		rc, transport, err := cli.connectEndpoints()
+		if err != nil {
+			return nil, err
+		}
+		cli.rc, cli.transport = rc, transport
+		return rc, nil
+	}
+	transport, err := nodeTransport(cli.NetworkURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	rc, err := rpc.DialContext(ctx, cli.NetworkURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", cli.NetworkURL, err)
+	}
+	cli.rc, cli.transport = rc, transport
+	return rc, nil
+}
+
+// ethClient returns the shared connection of rpcClient as an ethclient
+func (cli *CLI) ethClient() (*ethclient.Client, error) {
+	rc, err := cli.rpcClient()
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(rc), nil
+}
+
+// Close closes the connections to the nodes if they were used
+func (cli *CLI) Close() {
+	if cli.rc != nil {
+		cli.rc.Close()
+		cli.rc = nil
+	}
+	for _, e := range cli.endpoints {
+		if e.rc != nil {
+			e.rc.Close()
+		}
+	}
+	cli.endpoints = nil
+}
+
+// canSubscribe reports whether the node connection supports eth_subscribe.
+// http never does; a websocket or ipc node may still refuse it, so it is
+// probed once with a newHeads subscription.
+func (cli *CLI) canSubscribe(ctx context.Context) bool {
+	if cli.subscribe != nil {
+		return *cli.subscribe
+	}
+	ok := false
+	if rc, err := cli.rpcClient(); err == nil && cli.transport != TransportHTTP {
+		sub, err := rc.EthSubscribe(ctx, make(chan json.RawMessage), "newHeads")
+		if err == nil {
+			sub.Unsubscribe()
+			ok = true
+		} else if !strings.Contains(err.Error(), rpc.ErrNotificationsUnsupported.Error()) {
+			log.Println("new block subscription not available: ", err)
+		}
+	}
+	cli.subscribe = &ok
+	return ok
+}
+
+// newBlocks signals each new block of the node until ctx is done: from a
+// newHeads subscription when the connection supports it, otherwise by
+// polling every interval. A failed subscription falls back to polling.
+func (cli *CLI) newBlocks(ctx context.Context, interval time.Duration) <-chan struct{} {
+	blocks := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case blocks <- struct{}{}:
+		default:
+		}
+	}
+	var (
+		heads chan json.RawMessage
+		sub   *rpc.ClientSubscription
+	)
+	if cli.canSubscribe(ctx) {
+		heads = make(chan json.RawMessage, 16)
+		var err error
+		if sub, err = cli.rc.EthSubscribe(ctx, heads, "newHeads"); err != nil {
+			sub = nil
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer func() { ticker.Stop() }()
+		var subErr <-chan error
+		if sub != nil {
+			defer sub.Unsubscribe()
+			subErr = sub.Err()
+			// 订阅正常时不需要轮询
+			ticker.Stop()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heads:
+				signal()
+			case err := <-subErr:
+				log.Println("new block subscription failed, polling: ", err)
+				subErr, heads = nil, nil
+				ticker = time.NewTicker(interval)
+			case <-ticker.C:
+				signal()
+			}
+		}
+	}()
+	return blocks
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TypedDataField is a member of an EIP-712 struct type
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is the EIP-712 structured data passed to account_signTypedData
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+var typedArray = regexp.MustCompile(`^(.+)\[([0-9]*)\]$`)
+
+// Hash returns keccak256("\x19\x01" || domainSeparator || hashStruct(message))
+func (td *TypedData) Hash() ([]byte, error) {
+	if _, ok := td.Types["EIP712Domain"]; !ok {
+		return nil, fmt.Errorf("typed data has no EIP712Domain type")
+	}
+	domain, err := td.hashStruct("EIP712Domain", td.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash domain: %v", err)
+	}
+	message, err := td.hashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash message: %v", err)
+	}
+	return crypto.Keccak256([]byte{0x19, 0x01}, domain, message), nil
+}
+
+func (td *TypedData) hashStruct(name string, data map[string]interface{}) ([]byte, error) {
+	fields, ok := td.Types[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	enc := crypto.Keccak256([]byte(td.encodeType(name)))
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s.%s", name, field.Name)
+		}
+		encoded, err := td.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", name, field.Name, err)
+		}
+		enc = append(enc, encoded...)
+	}
+	return crypto.Keccak256(enc), nil
+}
+
+// encodeType returns the type signature of name followed by the sorted
+// signatures of all struct types it references
+func (td *TypedData) encodeType(name string) string {
+	deps := map[string]bool{}
+	td.dependencies(name, deps)
+	delete(deps, name)
+
+	sorted := make([]string, 0, len(deps))
+	for dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Strings(sorted)
+
+	var buf bytes.Buffer
+	for _, t := range append([]string{name}, sorted...) {
+		members := make([]string, len(td.Types[t]))
+		for i, field := range td.Types[t] {
+			members[i] = field.Type + " " + field.Name
+		}
+		buf.WriteString(t + "(" + strings.Join(members, ",") + ")")
+	}
+	return buf.String()
+}
+
+func (td *TypedData) dependencies(name string, found map[string]bool) {
+	name = baseType(name)
+	if found[name] {
+		return
+	}
+	if _, ok := td.Types[name]; !ok {
+		return
+	}
+	found[name] = true
+	for _, field := range td.Types[name] {
+		td.dependencies(field.Type, found)
+	}
+}
+
+func baseType(name string) string {
+	for {
+		m := typedArray.FindStringSubmatch(name)
+		if m == nil {
+			return name
+		}
+		name = m[1]
+	}
+}
+
+func (td *TypedData) encodeValue(typ string, value interface{}) ([]byte, error) {
+	if m := typedArray.FindStringSubmatch(typ); m != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array for %s", typ)
+		}
+		if m[2] != "" {
+			if n, _ := strconv.Atoi(m[2]); n != len(items) {
+				return nil, fmt.Errorf("expected %d items for %s, got %d", n, typ, len(items))
+			}
+		}
+		var enc []byte
+		for _, item := range items {
+			encoded, err := td.encodeValue(m[1], item)
+			if err != nil {
+				return nil, err
+			}
+			enc = append(enc, encoded...)
+		}
+		return crypto.Keccak256(enc), nil
+	}
+	if _, ok := td.Types[typ]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected object for %s", typ)
+		}
+		return td.hashStruct(typ, data)
+	}
+
+	switch {
+	case typ == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string")
+		}
+		return crypto.Keccak256([]byte(s)), nil
+	case typ == "bytes":
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	case strings.HasPrefix(typ, "bytes"):
+		n, err := strconv.Atoi(typ[5:])
+		if err != nil || n < 1 || n > 32 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > n {
+			return nil, fmt.Errorf("value too long for %s", typ)
+		}
+		return common.RightPadBytes(b, 32), nil
+	case typ == "address":
+		s, ok := value.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("invalid address")
+		}
+		return common.LeftPadBytes(common.HexToAddress(s).Bytes(), 32), nil
+	case typ == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool")
+		}
+		if b {
+			return math.PaddedBigBytes(big.NewInt(1), 32), nil
+		}
+		return make([]byte, 32), nil
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		n, err := typedInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(typ, "uint") && n.Sign() < 0 {
+			return nil, fmt.Errorf("negative value for %s", typ)
+		}
+		return math.PaddedBigBytes(math.U256(n), 32), nil
+	}
+	return nil, fmt.Errorf("unknown type %s", typ)
+}
+
+func typedBytes(value interface{}) ([]byte, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected hex string")
+	}
+	return hexutil.Decode(s)
+}
+
+// typedInteger accepts json numbers as well as decimal and 0x hex strings
+func typedInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case json.Number:
+		n, ok := new(big.Int).SetString(string(v), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", v)
+		}
+		return n, nil
+	case string:
+		n, ok := math.ParseBig256(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("expected integer")
+}
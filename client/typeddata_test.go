@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// mailTypedData is the example of EIP-712
+const mailTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallet", "type": "address"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+    "to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+    "contents": "Hello, Bob!"
+  }
+}`
+
+func TestTypedDataMail(t *testing.T) {
+	var td TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &td); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := td.encodeType("Mail"), "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; got != want {
+		t.Errorf("encodeType: got %s want %s", got, want)
+	}
+	if got, want := hexutil.Encode(crypto.Keccak256([]byte(td.encodeType("Mail")))), "0xa0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"; got != want {
+		t.Errorf("type hash: got %s want %s", got, want)
+	}
+	domain, err := td.hashStruct("EIP712Domain", td.Domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hexutil.Encode(domain), "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; got != want {
+		t.Errorf("domain separator: got %s want %s", got, want)
+	}
+	message, err := td.hashStruct("Mail", td.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hexutil.Encode(message), "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"; got != want {
+		t.Errorf("message hash: got %s want %s", got, want)
+	}
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hexutil.Encode(hash), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; got != want {
+		t.Errorf("hash: got %s want %s", got, want)
+	}
+}
+
+// TestTypedDataGeth compares the hash of a permit-like message using the
+// other member types with the implementation of go-ethereum
+func TestTypedDataGeth(t *testing.T) {
+	const permit = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"},
+      {"name": "salt", "type": "bytes32"}
+    ],
+    "Permit": [
+      {"name": "owner", "type": "address"},
+      {"name": "spenders", "type": "address[]"},
+      {"name": "value", "type": "uint256"},
+      {"name": "nonce", "type": "uint64"},
+      {"name": "allowed", "type": "bool"},
+      {"name": "memo", "type": "bytes"}
+    ]
+  },
+  "primaryType": "Permit",
+  "domain": {
+    "name": "Token",
+    "chainId": "0x539",
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+    "salt": "0x00000000000000000000000000000000000000000000000000000000000000ff"
+  },
+  "message": {
+    "owner": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+    "spenders": ["0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"],
+    "value": "115792089237316195423570985008687907853269984665640564039457584007913129639935",
+    "nonce": 7,
+    "allowed": true,
+    "memo": "0xdeadbeef"
+  }
+}`
+	var td TypedData
+	if err := json.Unmarshal([]byte(permit), &td); err != nil {
+		t.Fatal(err)
+	}
+	got, err := td.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gtd apitypes.TypedData
+	if err := json.Unmarshal([]byte(permit), &gtd); err != nil {
+		t.Fatal(err)
+	}
+	want, _, err := apitypes.TypedDataAndHash(gtd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hexutil.Encode(got) != hexutil.Encode(want) {
+		t.Errorf("hash: got %x want %x", got, want)
+	}
+}
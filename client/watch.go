@@ -0,0 +1,473 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+监听钱包的转账: watch 在每个新区块(newHeads 订阅, 节点不支持时轮询)读取区块中钱包账户收发 ether 的交易,
+以及 from 或 to 为钱包账户的 Transfer 事件(所有合约的, 不只是注册表中的 token), 实时打印.
+处理完的区块号保存在 watch.NAME[.NETWORK].json, 重新启动时从下一个区块继续; 节点断开或请求失败时等待后重新订阅.
+最近处理的区块 hash 也保存下来: 新区块的 parentHash 与之不符时链被重组, 回退到仍在链上的区块重新扫描.
+合约内部转出的 ether (internal transaction) 不在交易中, 不会显示.
+*/
+
+const (
+	// watchPollInterval is how often new blocks are polled when the node
+	// can't notify them
+	watchPollInterval = 2 * time.Second
+	// watchMaxBlocks is the number of blocks scanned at once when catching
+	// up, nodes limit the block range of eth_getLogs
+	watchMaxBlocks = 1000
+	// watchMaxBackoff is the longest wait before retrying after an error
+	watchMaxBackoff = time.Minute
+	// watchReorgDepth is the number of processed blocks whose hash is kept
+	// to find the last block still on the chain after a reorganization
+	watchReorgDepth = 64
+	// defaultWatchConfirmations is how deep the blocks are when printed,
+	// reorganizations rarely go deeper
+	defaultWatchConfirmations = 12
+)
+
+// errWatchReorg is returned by scan when a block doesn't follow the blocks
+// processed before
+var errWatchReorg = errors.New("chain reorganized")
+
+// watchBlock is a processed block
+type watchBlock struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// watchState is the last block processed by watch and the hashes of the
+// recent ones
+type watchState struct {
+	Block  uint64       `json:"block"`
+	Recent []watchBlock `json:"recent,omitempty"`
+}
+
+// watchTx is the part of a transaction of eth_getBlockByNumber used by
+// watch, decoded without types.Transaction to accept every transaction type
+type watchTx struct {
+	Hash  common.Hash     `json:"hash"`
+	From  common.Address  `json:"from"`
+	To    *common.Address `json:"to"`
+	Value *hexutil.Big    `json:"value"`
+	Index hexutil.Uint    `json:"transactionIndex"`
+}
+
+// movement is an ether or token transfer from or to an account of the
+// wallet; txIndex and logIndex order the movements of a block
+type movement struct {
+	txIndex  uint
+	logIndex int
+	tx       common.Hash
+	from, to string
+	amount   string
+}
+
+// watcher finds the movements of the accounts of a wallet in blocks
+type watcher struct {
+	rc       *rpc.Client
+	client   *ethclient.Client
+	accounts map[common.Address]bool
+	// topics are the accounts as indexed Transfer arguments
+	topics []common.Hash
+	tokens map[common.Address]*TokenMeta
+	// recent are the last processed blocks, oldest first
+	recent []watchBlock
+}
+
+// watchFile is the state file of watch for the wallet name on the network
+func (cli *CLI) watchFile(name string) string {
+	if cli.Network.Name != "" {
+		return filepath.Join(cli.DataPath, "watch."+name+"."+cli.Network.Name+".json")
+	}
+	return filepath.Join(cli.DataPath, "watch."+name+".json")
+}
+
+// loadWatchState reads the state file, nil if watch never ran
+func loadWatchState(file string) (*watchState, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := new(watchState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return state, nil
+}
+
+func saveWatchState(file string, state *watchState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return utils.WriteKeyFile(file, data)
+}
+
+// Watch prints the ether and token transfers from and to the accounts of
+// the wallet name as the blocks arrive, confirmations blocks deep, until it
+// is interrupted. It starts from fromBlock if not negative, otherwise after
+// the last block processed by the previous run, or at the next block.
+func (cli *CLI) Watch(name string, fromBlock int64, confirmations uint64) error {
+	addrs, err := cli.walletAccounts(name)
+	if err != nil {
+		return err
+	}
+	tokens, err := loadTokens(cli.TokensFile)
+	if err != nil {
+		return err
+	}
+	rc, err := cli.rpcClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigc)
+	go func() {
+		select {
+		case <-sigc:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	w := &watcher{rc: rc, client: ethclient.NewClient(rc), accounts: make(map[common.Address]bool), tokens: make(map[common.Address]*TokenMeta)}
+	for _, addr := range addrs {
+		w.accounts[addr] = true
+		w.topics = append(w.topics, common.BytesToHash(addr.Bytes()))
+	}
+	for i := range tokens {
+		token, err := cli.tokenMeta(ctx, w.client, &tokens[i])
+		if err != nil {
+			return fmt.Errorf("token %s: %v", tokens[i].Key(), err)
+		}
+		w.tokens[token.Address] = token
+	}
+
+	file := cli.watchFile(name)
+	state, err := loadWatchState(file)
+	if err != nil {
+		return err
+	}
+	var next uint64
+	switch {
+	case fromBlock >= 0:
+		next = uint64(fromBlock)
+	case state != nil:
+		next = state.Block + 1
+		w.recent = state.Recent
+	default:
+		head, err := w.client.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		next = head + 1
+	}
+	log.Printf("watching %d accounts of %s from block %d, state in %s\n", len(addrs), name, next, file)
+
+	backoff := time.Second
+	for {
+		start := next
+		err := cli.watchBlocks(ctx, w, file, &next, confirmations)
+		if ctx.Err() != nil {
+			break
+		}
+		if next > start {
+			backoff = time.Second
+		}
+		log.Printf("watch failed at block %d: %v, retrying in %s\n", next, err, backoff)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if backoff *= 2; backoff > watchMaxBackoff {
+			backoff = watchMaxBackoff
+		}
+	}
+	if next > 0 {
+		log.Printf("stopped after block %d\n", next-1)
+	}
+	return nil
+}
+
+// watchBlocks processes the blocks from *next at each new block and saves
+// the progress in file, until ctx is done or an error. The new block
+// subscription is made again on each call, after a reconnection.
+func (cli *CLI) watchBlocks(ctx context.Context, w *watcher, file string, next *uint64, confirmations uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks := cli.newBlocks(ctx, watchPollInterval)
+	for {
+		head, err := w.client.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		for head >= confirmations && *next <= head-confirmations {
+			end := *next + watchMaxBlocks - 1
+			if end > head-confirmations {
+				end = head - confirmations
+			}
+			err := w.scan(ctx, *next, end, func(block uint64, moved bool) error {
+				*next = block + 1
+				// the state is written at the end of each range and after
+				// the blocks printed, to not print them again on restart
+				if moved || block == end {
+					return saveWatchState(file, &watchState{Block: block, Recent: w.recent})
+				}
+				return nil
+			})
+			if err == errWatchReorg {
+				if err = w.rewind(ctx, next); err == nil && *next > 0 {
+					err = saveWatchState(file, &watchState{Block: *next - 1, Recent: w.recent})
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-blocks:
+		}
+	}
+}
+
+// scan prints the movements of the blocks from to to in order, done is
+// called after each block. It fails with errWatchReorg when a block is not
+// the child of the last processed one, or its logs are of another block.
+func (w *watcher) scan(ctx context.Context, from, to uint64, done func(block uint64, moved bool) error) error {
+	logs, err := w.transferLogs(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for n := from; n <= to; n++ {
+		block, err := w.block(ctx, n)
+		if err != nil {
+			return err
+		}
+		if last := len(w.recent) - 1; last >= 0 && w.recent[last].Number+1 == n && w.recent[last].Hash != block.ParentHash {
+			return errWatchReorg
+		}
+		for _, l := range logs[n] {
+			if l.BlockHash != block.Hash {
+				return errWatchReorg
+			}
+		}
+		moves, err := w.etherMovements(ctx, block)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs[n] {
+			if m := w.tokenMovement(l); m != nil {
+				moves = append(moves, *m)
+			}
+		}
+		sort.SliceStable(moves, func(i, j int) bool {
+			if moves[i].txIndex != moves[j].txIndex {
+				return moves[i].txIndex < moves[j].txIndex
+			}
+			return moves[i].logIndex < moves[j].logIndex
+		})
+		for _, m := range moves {
+			fmt.Printf("block %d %-4s %s from %s to %s tx %s\n", n, w.direction(m), m.amount, m.from, m.to, m.tx.Hex())
+		}
+		w.recent = append(w.recent, watchBlock{Number: n, Hash: block.Hash})
+		if len(w.recent) > watchReorgDepth {
+			w.recent = w.recent[len(w.recent)-watchReorgDepth:]
+		}
+		if err := done(n, len(moves) > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind drops the recent blocks that are no longer on the chain and sets
+// *next after the last one still on it
+func (w *watcher) rewind(ctx context.Context, next *uint64) error {
+	for len(w.recent) > 0 {
+		last := w.recent[len(w.recent)-1]
+		block, err := w.blockHeader(ctx, last.Number)
+		if err != nil {
+			return err
+		}
+		if block.Hash == last.Hash {
+			if last.Number+1 < *next {
+				log.Printf("blocks %d to %d were reorganized, the transfers printed for them may be reverted; scanning them again\n", last.Number+1, *next-1)
+			}
+			*next = last.Number + 1
+			return nil
+		}
+		w.recent = w.recent[:len(w.recent)-1]
+	}
+	return fmt.Errorf("chain reorganized deeper than the last %d blocks watched, start again with -fromblock", watchReorgDepth)
+}
+
+// direction is IN, OUT or SELF between two accounts of the wallet
+func (w *watcher) direction(m movement) string {
+	in, out := w.accounts[common.HexToAddress(m.to)], w.accounts[common.HexToAddress(m.from)]
+	switch {
+	case in && out:
+		return "SELF"
+	case in:
+		return "IN"
+	}
+	return "OUT"
+}
+
+// transferLogs returns the Transfer logs of any contract from or to the
+// accounts in the blocks from to to, by block
+func (w *watcher) transferLogs(ctx context.Context, from, to uint64) (map[uint64][]types.Log, error) {
+	transfer := erc20ABI.Events["Transfer"].ID
+	byBlock := make(map[uint64][]types.Log)
+	type logID struct {
+		tx    common.Hash
+		index uint
+	}
+	seen := make(map[logID]bool)
+	// a filter matches the accounts as sender or as recipient, not both
+	for _, topics := range [][][]common.Hash{{{transfer}, w.topics}, {{transfer}, nil, w.topics}} {
+		logs, err := w.client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range logs {
+			key := logID{l.TxHash, l.Index}
+			if l.Removed || seen[key] {
+				continue
+			}
+			seen[key] = true
+			byBlock[l.BlockNumber] = append(byBlock[l.BlockNumber], l)
+		}
+	}
+	return byBlock, nil
+}
+
+// watchBlockTxs is the part of a block of eth_getBlockByNumber used by
+// watch, the hashes are read from the node and not computed from a header
+type watchBlockTxs struct {
+	Hash         common.Hash `json:"hash"`
+	ParentHash   common.Hash `json:"parentHash"`
+	Transactions []watchTx   `json:"transactions"`
+}
+
+// block returns the block number with its transactions
+func (w *watcher) block(ctx context.Context, number uint64) (*watchBlockTxs, error) {
+	return w.getBlock(ctx, number, true)
+}
+
+// blockHeader returns the hashes of the block number, without transactions
+func (w *watcher) blockHeader(ctx context.Context, number uint64) (*watchBlockTxs, error) {
+	return w.getBlock(ctx, number, false)
+}
+
+func (w *watcher) getBlock(ctx context.Context, number uint64, txs bool) (*watchBlockTxs, error) {
+	block := new(watchBlockTxs)
+	if err := w.rc.CallContext(ctx, block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), txs); err != nil {
+		return nil, err
+	}
+	if block.Hash == (common.Hash{}) {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return block, nil
+}
+
+// etherMovements returns the successful transactions of block sending
+// ether from or to the accounts
+func (w *watcher) etherMovements(ctx context.Context, block *watchBlockTxs) ([]movement, error) {
+	var moves []movement
+	for _, tx := range block.Transactions {
+		if tx.Value == nil || tx.Value.ToInt().Sign() == 0 {
+			continue
+		}
+		if !w.accounts[tx.From] && (tx.To == nil || !w.accounts[*tx.To]) {
+			continue
+		}
+		var receipt struct {
+			Status          hexutil.Uint64  `json:"status"`
+			ContractAddress *common.Address `json:"contractAddress"`
+		}
+		if err := w.rc.CallContext(ctx, &receipt, "eth_getTransactionReceipt", tx.Hash); err != nil {
+			return nil, err
+		}
+		if receipt.Status != hexutil.Uint64(types.ReceiptStatusSuccessful) {
+			// a reverted transaction moves no ether
+			continue
+		}
+		to := tx.To
+		if to == nil {
+			to = receipt.ContractAddress
+		}
+		m := movement{txIndex: uint(tx.Index), logIndex: -1, tx: tx.Hash, from: tx.From.Hex(), amount: utils.FormatEther(tx.Value.ToInt()) + " ether"}
+		if to != nil {
+			m.to = to.Hex()
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// tokenMovement decodes a Transfer log, nil if it is not an ERC-20 transfer
+// (ERC-721 has the same event with the token id indexed) or moves nothing.
+// Tokens missing from the registry are shown in base units.
+func (w *watcher) tokenMovement(l types.Log) *movement {
+	if len(l.Topics) != 3 || len(l.Data) != 32 {
+		return nil
+	}
+	value := new(big.Int).SetBytes(l.Data)
+	if value.Sign() == 0 {
+		// anyone can log a transfer of 0 from any address, e.g. to put a
+		// lookalike address in the history of the wallet
+		return nil
+	}
+	amount := fmt.Sprintf("%s base units of unregistered token %s", value, l.Address.Hex())
+	if token, ok := w.tokens[l.Address]; ok {
+		amount = token.Format(value)
+	}
+	return &movement{
+		txIndex:  l.TxIndex,
+		logIndex: int(l.Index),
+		tx:       l.TxHash,
+		from:     common.BytesToAddress(l.Topics[1].Bytes()).Hex(),
+		to:       common.BytesToAddress(l.Topics[2].Bytes()).Hex(),
+		amount:   amount,
+	}
+}
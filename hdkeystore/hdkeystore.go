@@ -13,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
 )
 
 // HDkeyStore ...
@@ -25,9 +26,12 @@ type HDkeyStore struct {
 
 // NewKeyFromECDSA ...
 func NewKeyFromECDSA(privateKeyECDSA *ecdsa.PrivateKey) *keystore.Key {
-	id := utils.NewRandom()
+	id, err := uuid.FromBytes(utils.NewRandom())
+	if err != nil {
+		panic(err)
+	}
 	key := &keystore.Key{
-		Id:         []byte(id),
+		Id:         id,
 		Address:    crypto.PubkeyToAddress(privateKeyECDSA.PublicKey),
 		PrivateKey: privateKeyECDSA,
 	}
@@ -92,17 +96,20 @@ func (ks *HDkeyStore) SignTx(account common.Address, tx *types.Transaction, chai
 
 	// fmt.Printf("%+v\n", ks)
 	// Sign the transaction and verify the sender to avoid hardware fault surprises
-	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, ks.PrivateKeyECDSA)
+	signer := types.Signer(types.HomesteadSigner{})
+	if chainID != nil {
+		signer = types.LatestSignerForChainID(chainID)
+	}
+	signedTx, err := types.SignTx(tx, signer, ks.PrivateKeyECDSA)
 	if err != nil {
 		return nil, err
 	}
 
-	msg, err := signedTx.AsMessage(types.HomesteadSigner{})
+	sender, err := types.Sender(signer, signedTx)
 	if err != nil {
 		return nil, err
 	}
 
-	sender := msg.From()
 	if sender != account {
 		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Hex(), sender.Hex())
 	}
@@ -235,17 +235,20 @@ func (w *Wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	}
 
 	// Sign the transaction and verify the sender to avoid hardware fault surprises
-	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, privateKey)
+	signer := types.Signer(types.HomesteadSigner{})
+	if chainID != nil {
+		signer = types.LatestSignerForChainID(chainID)
+	}
+	signedTx, err := types.SignTx(tx, signer, privateKey)
 	if err != nil {
 		return nil, err
 	}
 
-	msg, err := signedTx.AsMessage(types.HomesteadSigner{})
+	sender, err := types.Sender(signer, signedTx)
 	if err != nil {
 		return nil, err
 	}
 
-	sender := msg.From()
 	if sender != account.Address {
 		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), sender.Hex())
 	}
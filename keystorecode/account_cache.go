@@ -79,6 +79,7 @@ type accountCache struct {
 	throttle *time.Timer                           // 计时器 ?
 	notify   chan struct{}                         // 添加新用户时, 进行通知
 	fileC    fileCache                             // 根据文件的状态, 可以推出create, update, delelte的数量
+	lister   func() ([]accounts.Account, error)    // 非文件存储(数据库)时, 代替目录扫描列出所有账户
 }
 
 func newAccountCache(keydir string) (*accountCache, chan struct{}) {
@@ -237,6 +238,24 @@ func (ac *accountCache) find(a accounts.Account) (accounts.Account, error) {
 func (ac *accountCache) maybeReload() {
 	ac.mu.Lock()
 
+	// Database backed caches have no directory to watch, reload throttled
+	if ac.lister != nil {
+		if ac.throttle == nil {
+			ac.throttle = time.NewTimer(minReloadInterval)
+		} else {
+			select {
+			case <-ac.throttle.C:
+			default:
+				ac.mu.Unlock()
+				return // The cache was reloaded recently.
+			}
+			ac.throttle.Reset(minReloadInterval)
+		}
+		ac.mu.Unlock()
+		ac.listAccounts()
+		return
+	}
+
 	if ac.watcher.running {
 		ac.mu.Unlock()
 		return // A watcher is running and will keep the cache up-to-date.
@@ -274,6 +293,45 @@ func (ac *accountCache) close() {
 	ac.mu.Unlock()
 }
 
+// listAccounts reloads the accounts of a database backed cache and updates
+// the account cache accordingly
+func (ac *accountCache) listAccounts() error {
+	accs, err := ac.lister()
+	if err != nil {
+		log.Debug("Failed to reload keystore contents", "err", err)
+		return err
+	}
+	current := make(map[accounts.Account]bool, len(accs))
+	for _, a := range accs {
+		current[a] = true
+	}
+
+	ac.mu.Lock()
+	var removed []accounts.Account
+	for _, a := range ac.all {
+		if !current[a] {
+			removed = append(removed, a)
+		}
+		delete(current, a)
+	}
+	ac.mu.Unlock()
+
+	if len(removed) == 0 && len(current) == 0 {
+		return nil
+	}
+	for _, a := range removed {
+		ac.delete(a)
+	}
+	for a := range current {
+		ac.add(a)
+	}
+	select {
+	case ac.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 // scanAccounts checks if any changes have occurred on the filesystem, and
 // updates the account cache accordingly
 func (ac *accountCache) scanAccounts() error {
@@ -0,0 +1,356 @@
+package keystorecode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+keyStoreDB 实现了 type keyStore interface{}
+与 keyStorePassphrase 相同的 V3 加密 json, 按地址存储在 bolt 数据库中,
+适用于大量账户(一个账户一个文件时目录扫描很慢)
+*/
+
+// keysBucket holds address -> encrypted V3 key json
+var keysBucket = []byte("keys")
+
+type keyStoreDB struct {
+	db      *bolt.DB
+	path    string
+	scryptN int
+	scryptP int
+}
+
+// openKeyStoreDB opens the database at path. bolt locks the file: a
+// read-only database is shared with the other readers, e.g. a transfer
+// while the agent serves the wallet, a writable one is exclusive.
+func openKeyStoreDB(path string, scryptN, scryptP int, readOnly bool) (*keyStoreDB, error) {
+	if readOnly {
+		// fail instead of waiting forever on a writer
+		db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		return &keyStoreDB{db: db, path: path, scryptN: scryptN, scryptP: scryptP}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(keysBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &keyStoreDB{db: db, path: path, scryptN: scryptN, scryptP: scryptP}, nil
+}
+
+// GetKey account.Address -> Key, the filename is not needed as keys are stored by address
+func (ks *keyStoreDB) GetKey(addr common.Address, filename, auth string) (*Key, error) {
+	keyjson, err := ks.getKeyJSON(addr)
+	if err != nil {
+		return nil, err
+	}
+	key, err := DecryptKey(keyjson, auth)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure we're really operating on the requested key (no swap attacks)
+	if key.Address != addr {
+		return nil, fmt.Errorf("key content mismatch: have account %x, want %x", key.Address, addr)
+	}
+	return key, nil
+}
+
+func (ks *keyStoreDB) StoreKey(filename string, key *Key, auth string) error {
+	keyjson, err := EncryptKey(key, auth, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return err
+	}
+	// Verify that we can decrypt the json before it replaces anything
+	if _, err := DecryptKey(keyjson, auth); err != nil {
+		return fmt.Errorf("failed to verify the encrypted key: %v", err)
+	}
+	return ks.putKeyJSON(key.Address, keyjson)
+}
+
+// JoinPath returns the account url path inside the database, as keys are
+// stored by address the path is <db>/<address hex> whatever the file name
+func (ks *keyStoreDB) JoinPath(filename string) string {
+	if i := strings.LastIndex(filename, "--"); i >= 0 {
+		filename = filename[i+2:]
+	}
+	return filepath.Join(ks.path, strings.TrimPrefix(strings.ToLower(filename), "0x"))
+}
+
+func (ks *keyStoreDB) getKeyJSON(addr common.Address) ([]byte, error) {
+	var keyjson []byte
+	err := ks.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keysBucket)
+		if b == nil {
+			return ErrNoMatch
+		}
+		v := b.Get(addr.Bytes())
+		if v == nil {
+			return ErrNoMatch
+		}
+		keyjson = append([]byte{}, v...)
+		return nil
+	})
+	return keyjson, err
+}
+
+func (ks *keyStoreDB) putKeyJSON(addr common.Address, keyjson []byte) error {
+	return ks.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(keysBucket).Put(addr.Bytes(), keyjson)
+	})
+}
+
+func (ks *keyStoreDB) deleteKey(addr common.Address) error {
+	return ks.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(keysBucket).Delete(addr.Bytes())
+	})
+}
+
+// accounts lists every key in the database for the account cache
+func (ks *keyStoreDB) accounts() ([]accounts.Account, error) {
+	accs := []accounts.Account{}
+	err := ks.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keysBucket)
+		if b == nil {
+			// a read-only database created by another version
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			addr := common.BytesToAddress(k)
+			accs = append(accs, accounts.Account{
+				Address: addr,
+				URL:     accounts.URL{Scheme: KeyStoreScheme, Path: ks.JoinPath(hex.EncodeToString(addr[:]))},
+			})
+			return nil
+		})
+	})
+	return accs, err
+}
+
+// NewDBKeyStore creates a keystore whose keys are stored in the bolt
+// database at path instead of one file per key.
+func NewDBKeyStore(path string, scryptN, scryptP int) (*KeyStore, error) {
+	return newDBKeyStore(path, scryptN, scryptP, false)
+}
+
+// NewReadOnlyDBKeyStore opens the existing database at path to list and
+// decrypt keys without locking other readers out; new accounts, updates
+// and deletes fail.
+func NewReadOnlyDBKeyStore(path string, scryptN, scryptP int) (*KeyStore, error) {
+	return newDBKeyStore(path, scryptN, scryptP, true)
+}
+
+func newDBKeyStore(path string, scryptN, scryptP int, readOnly bool) (*KeyStore, error) {
+	path, _ = filepath.Abs(path)
+	storage, err := openKeyStoreDB(path, scryptN, scryptP, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeyStore{storage: storage}
+	ks.init(path)
+	return ks, nil
+}
+
+// Close releases the database of a database backed keystore, the keystore
+// can't read keys anymore afterwards. It is a no-op for key directories.
+func (ks *KeyStore) Close() error {
+	if store, ok := ks.storage.(*keyStoreDB); ok {
+		return store.db.Close()
+	}
+	return nil
+}
+
+// MigrateFilesToDB copies the key files of keydir into the database at
+// dbPath, the encrypted json is copied as is so no password is needed.
+// Keys already in the database, e.g. after an interrupted run, are
+// skipped, a different key for the same address is an error.
+func MigrateFilesToDB(keydir, dbPath string) (int, error) {
+	store, err := openKeyStoreDB(dbPath, StandardScryptN, StandardScryptP, false)
+	if err != nil {
+		return 0, err
+	}
+	defer store.db.Close()
+
+	keys, err := readKeyDir(keydir)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, k := range keys {
+		if stored, err := store.getKeyJSON(k.addr); err == nil {
+			if !sameKeyJSON(stored, k.json) {
+				return n, fmt.Errorf("%s: another key of account %s is already in the database", k.name, k.addr.Hex())
+			}
+			continue
+		}
+		if err := store.putKeyJSON(k.addr, k.json); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MigrateDBToFiles writes the keys of the database at dbPath into keydir
+// using the usual key file naming. Accounts that already have the same
+// key file in keydir are skipped, a different key file is an error.
+func MigrateDBToFiles(dbPath, keydir string) (int, error) {
+	keys, err := ReadDBKeys(dbPath)
+	if err != nil {
+		return 0, err
+	}
+	files, err := readKeyDir(keydir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	existing := make(map[common.Address]keyDirFile)
+	for _, f := range files {
+		existing[f.addr] = f
+	}
+	n := 0
+	for addr, keyjson := range keys {
+		if f, ok := existing[addr]; ok {
+			if !sameKeyJSON(f.json, keyjson) {
+				return n, fmt.Errorf("%s: another key of account %s is already in %s", f.name, addr.Hex(), keydir)
+			}
+			continue
+		}
+		if err := writeKeyFile(filepath.Join(keydir, keyFileName(addr)), keyjson); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ReadDBKeys returns the encrypted key json of every account in the
+// database at dbPath, opened read-only, e.g. for a backup.
+func ReadDBKeys(dbPath string) (map[common.Address][]byte, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+	store, err := openKeyStoreDB(dbPath, StandardScryptN, StandardScryptP, true)
+	if err != nil {
+		return nil, err
+	}
+	defer store.db.Close()
+
+	accs, err := store.accounts()
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[common.Address][]byte, len(accs))
+	for _, a := range accs {
+		keyjson, err := store.getKeyJSON(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		keys[a.Address] = keyjson
+	}
+	return keys, nil
+}
+
+// WriteDBKeys stores the encrypted key json of the accounts into the
+// database at dbPath, e.g. on restore, replacing the keys already there.
+func WriteDBKeys(dbPath string, keys map[common.Address][]byte) error {
+	store, err := openKeyStoreDB(dbPath, StandardScryptN, StandardScryptP, false)
+	if err != nil {
+		return err
+	}
+	defer store.db.Close()
+	return store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keysBucket)
+		for addr, keyjson := range keys {
+			if err := b.Put(addr.Bytes(), keyjson); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// keyDirFile is a key file of a keystore directory
+type keyDirFile struct {
+	name string
+	addr common.Address
+	json []byte
+}
+
+func readKeyDir(keydir string) ([]keyDirFile, error) {
+	files, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []keyDirFile
+	for _, fi := range files {
+		if nonKeyFile(fi) {
+			continue
+		}
+		keyjson, err := ioutil.ReadFile(filepath.Join(keydir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		addr, err := keyJSONAddress(keyjson)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fi.Name(), err)
+		}
+		keys = append(keys, keyDirFile{name: fi.Name(), addr: addr, json: keyjson})
+	}
+	return keys, nil
+}
+
+// sameKeyJSON reports whether both json hold the same encrypted key, the
+// formatting of the json may differ
+func sameKeyJSON(a, b []byte) bool {
+	var ka, kb struct {
+		Address string `json:"address"`
+		Crypto  struct {
+			CipherText string `json:"ciphertext"`
+		} `json:"crypto"`
+	}
+	if json.Unmarshal(a, &ka) != nil || json.Unmarshal(b, &kb) != nil {
+		return false
+	}
+	if ka.Crypto.CipherText == "" {
+		return string(a) == string(b)
+	}
+	return common.HexToAddress(ka.Address) == common.HexToAddress(kb.Address) &&
+		strings.EqualFold(ka.Crypto.CipherText, kb.Crypto.CipherText)
+}
+
+func keyJSONAddress(keyjson []byte) (common.Address, error) {
+	var key struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(keyjson, &key); err != nil {
+		return common.Address{}, err
+	}
+	addr := common.HexToAddress(key.Address)
+	if (addr == common.Address{}) {
+		return common.Address{}, fmt.Errorf("missing or zero address")
+	}
+	return addr, nil
+}
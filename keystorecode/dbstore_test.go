@@ -0,0 +1,204 @@
+package keystorecode
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+)
+
+func tmpDBKeyStore(t *testing.T) (string, *KeyStore) {
+	d, err := ioutil.TempDir("", "eth-keystore-db-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewDBKeyStore(filepath.Join(d, "keys.db"), veryLightScryptN, veryLightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, ks
+}
+
+func TestDBKeyStore(t *testing.T) {
+	dir, ks := tmpDBKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	a, err := ks.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ks.HasAddress(a.Address) {
+		t.Errorf("HasAccount(%x) should've returned true", a.Address)
+	}
+	if err := ks.Update(a, "foo", "bar"); err != nil {
+		t.Errorf("Update error: %v", err)
+	}
+	if err := ks.Unlock(a, "foo"); err == nil {
+		t.Errorf("Unlock with the old passphrase should fail")
+	}
+	if err := ks.Unlock(a, "bar"); err != nil {
+		t.Fatalf("Unlock error: %v", err)
+	}
+	if _, err := ks.SignHash(accounts.Account{Address: a.Address}, testSigData); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Delete(a, "bar"); err != nil {
+		t.Errorf("Delete error: %v", err)
+	}
+	if ks.HasAddress(a.Address) {
+		t.Errorf("HasAccount(%x) should've returned false after Delete", a.Address)
+	}
+	if len(ks.Accounts()) != 0 {
+		t.Errorf("expected no accounts after Delete, got %d", len(ks.Accounts()))
+	}
+}
+
+// Tests that wallet notifications are fired for a database backed keystore.
+func TestDBWalletNotifications(t *testing.T) {
+	dir, ks := tmpDBKeyStore(t)
+	defer os.RemoveAll(dir)
+
+	updates := make(chan accounts.WalletEvent, 10)
+	sub := ks.Subscribe(updates)
+	defer sub.Unsubscribe()
+
+	a, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-updates:
+		if ev.Kind != accounts.WalletArrived || ev.Wallet.Accounts()[0] != a {
+			t.Errorf("unexpected event %v for %x", ev.Kind, ev.Wallet.Accounts()[0].Address)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no wallet arrived event")
+	}
+	if err := ks.Delete(a, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-updates:
+		if ev.Kind != accounts.WalletDropped {
+			t.Errorf("unexpected event %v, want WalletDropped", ev.Kind)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no wallet dropped event")
+	}
+}
+
+func TestMigrateFilesAndDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eth-keystore-migrate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keydir := filepath.Join(dir, "keys")
+	var addrs []accounts.Account
+	for i := 0; i < 3; i++ {
+		addr, err := StoreKey(keydir, "foo", veryLightScryptN, veryLightScryptP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, accounts.Account{Address: addr})
+	}
+
+	dbPath := filepath.Join(dir, "keys.db")
+	if n, err := MigrateFilesToDB(keydir, dbPath); err != nil || n != len(addrs) {
+		t.Fatalf("MigrateFilesToDB: migrated %d, err %v", n, err)
+	}
+	// a second run, e.g. after an interrupted one, skips the migrated keys
+	if n, err := MigrateFilesToDB(keydir, dbPath); err != nil || n != 0 {
+		t.Errorf("MigrateFilesToDB rerun: migrated %d, err %v", n, err)
+	}
+
+	backdir := filepath.Join(dir, "back")
+	if n, err := MigrateDBToFiles(dbPath, backdir); err != nil || n != len(addrs) {
+		t.Fatalf("MigrateDBToFiles: migrated %d, err %v", n, err)
+	}
+	// the key files are already there, no duplicates are written
+	if n, err := MigrateDBToFiles(dbPath, backdir); err != nil || n != 0 {
+		t.Errorf("MigrateDBToFiles rerun: migrated %d, err %v", n, err)
+	}
+	if files, _ := ioutil.ReadDir(backdir); len(files) != len(addrs) {
+		t.Errorf("%d key files after the rerun, want %d", len(files), len(addrs))
+	}
+	ks := NewKeyStore(backdir, veryLightScryptN, veryLightScryptP)
+	for _, a := range addrs {
+		if !ks.HasAddress(a.Address) {
+			t.Errorf("account %x missing after migration", a.Address)
+		}
+		if err := ks.Unlock(a, "foo"); err != nil {
+			t.Errorf("account %x can't be unlocked after migration: %v", a.Address, err)
+		}
+	}
+
+	// another key of a migrated account must not be skipped silently
+	other, err := NewKeyStore(filepath.Join(dir, "other"), veryLightScryptN, veryLightScryptP).NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherjson, err := ioutil.ReadFile(other.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherjson = []byte(strings.Replace(string(otherjson), hex.EncodeToString(other.Address[:]), hex.EncodeToString(addrs[0].Address[:]), 1))
+	if err := ioutil.WriteFile(filepath.Join(keydir, "conflict"), otherjson, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateFilesToDB(keydir, dbPath); err == nil {
+		t.Errorf("MigrateFilesToDB should refuse another key of a migrated account")
+	}
+	if err := ioutil.WriteFile(filepath.Join(backdir, "conflict"), otherjson, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateDBToFiles(dbPath, backdir); err == nil {
+		t.Errorf("MigrateDBToFiles should refuse another key file of a migrated account")
+	}
+}
+
+// Tests that read-only keystores share the database, e.g. with an agent
+// serving the wallet, and refuse writes.
+func TestReadOnlyDBKeyStore(t *testing.T) {
+	dir, ks := tmpDBKeyStore(t)
+	defer os.RemoveAll(dir)
+	a, err := ks.NewAccount("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Close()
+
+	path := filepath.Join(dir, "keys.db")
+	agent, err := NewReadOnlyDBKeyStore(path, veryLightScryptN, veryLightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+	other, err := NewReadOnlyDBKeyStore(path, veryLightScryptN, veryLightScryptP)
+	if err != nil {
+		t.Fatalf("second reader: %v", err)
+	}
+	defer other.Close()
+	if !other.HasAddress(a.Address) {
+		t.Errorf("HasAccount(%x) should've returned true", a.Address)
+	}
+	key, err := other.GetKey(a, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Address != a.Address {
+		t.Errorf("GetKey returned the key of %x, want %x", key.Address, a.Address)
+	}
+	if _, err := other.GetKey(a, "bar"); err == nil {
+		t.Errorf("GetKey with a wrong passphrase should fail")
+	}
+	if _, err := other.NewAccount("foo"); err == nil {
+		t.Errorf("NewAccount should fail on a read-only keystore")
+	}
+}
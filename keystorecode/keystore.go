@@ -116,12 +116,19 @@ func (ks *KeyStore) init(keydir string) {
 	// Initialize the set of unlocked keys and the account cache
 	ks.unlocked = make(map[common.Address]*unlocked)
 	ks.cache, ks.changes = newAccountCache(keydir)
+	// Keys kept in a database are listed from it instead of the directory
+	if store, ok := ks.storage.(*keyStoreDB); ok {
+		ks.cache.lister = store.accounts
+	}
 
 	// TODO: In order for this finalizer to work, there must be no references
 	// to ks. addressCache doesn't keep a reference but unlocked keys do,
 	// so the finalizer will not trigger until all timed unlocks have expired.
 	runtime.SetFinalizer(ks, func(m *KeyStore) {
 		m.cache.close()
+		if store, ok := m.storage.(*keyStoreDB); ok {
+			store.db.Close()
+		}
 	})
 	// Create the initial list of wallets from the cache
 	accs := ks.cache.accounts()
@@ -270,7 +277,11 @@ func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
 	// The order is crucial here. The key is dropped from the
 	// cache after the file is gone so that a reload happening in
 	// between won't insert it into the cache again.
-	err = os.Remove(a.URL.Path)
+	if store, ok := ks.storage.(*keyStoreDB); ok {
+		err = store.deleteKey(a.Address)
+	} else {
+		err = os.Remove(a.URL.Path)
+	}
 	if err == nil {
 		ks.cache.delete(a)
 		ks.refreshWallets()
@@ -303,9 +314,9 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	if !found {
 		return nil, ErrLocked
 	}
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
+	// Depending on the presence of the chain ID, sign with the latest signer or homestead
 	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), unlockedKey.PrivateKey)
+		return types.SignTx(tx, types.LatestSignerForChainID(chainID), unlockedKey.PrivateKey)
 	}
 	return types.SignTx(tx, types.HomesteadSigner{}, unlockedKey.PrivateKey)
 }
@@ -331,9 +342,9 @@ func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string,
 	}
 	defer zeroKey(key.PrivateKey)
 
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
+	// Depending on the presence of the chain ID, sign with the latest signer or homestead
 	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), key.PrivateKey)
+		return types.SignTx(tx, types.LatestSignerForChainID(chainID), key.PrivateKey)
 	}
 	return types.SignTx(tx, types.HomesteadSigner{}, key.PrivateKey)
 }
@@ -401,6 +412,12 @@ func (ks *KeyStore) Find(a accounts.Account) (accounts.Account, error) {
 	return a, err
 }
 
+// GetKey decrypts the key of a with its passphrase auth
+func (ks *KeyStore) GetKey(a accounts.Account, auth string) (*Key, error) {
+	_, key, err := ks.getDecryptedKey(a, auth)
+	return key, err
+}
+
 func (ks *KeyStore) getDecryptedKey(a accounts.Account, auth string) (accounts.Account, *Key, error) {
 	a, err := ks.Find(a)
 	if err != nil {
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// units of ether and their number of decimals in wei
+var units = map[string]int{
+	"wei":    0,
+	"kwei":   3,
+	"mwei":   6,
+	"gwei":   9,
+	"szabo":  12,
+	"finney": 15,
+	"ether":  18,
+}
+
+// ParseAmount parses a decimal amount with an ether unit, e.g. "1.5ether",
+// "20gwei" or "300000wei", into wei without going through floats. The unit
+// is required, "100" could mean 100 wei or 100 ether; only 0 has none.
+func ParseAmount(s string) (*big.Int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	num := strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyz")
+	unit := strings.TrimSpace(s[len(num):])
+	num = strings.TrimSpace(num)
+
+	decimals := 0
+	if unit != "" {
+		d, ok := units[unit]
+		if !ok {
+			return nil, fmt.Errorf("unknown unit %q in amount %q", unit, s)
+		}
+		decimals = d
+	}
+	amount, err := ParseUnits(num, decimals)
+	if err != nil {
+		return nil, err
+	}
+	if unit == "" && amount.Sign() != 0 {
+		return nil, fmt.Errorf("amount %q has no unit, e.g. %sether or %swei", s, num, num)
+	}
+	return amount, nil
+}
+
+// maxAmount is the largest amount of a uint256, in base units
+var maxAmount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// ParseUnits parses the decimal number s scaled by 10^decimals, it fails if
+// s has more fraction digits than decimals or doesn't fit in a uint256.
+// Both sides of the decimal point need digits, e.g. 0.5 and not .5 or 5.
+func ParseUnits(s string, decimals int) (*big.Int, error) {
+	if s == "" || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" || (frac == "" && len(whole) < len(s)) {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > decimals {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", decimals-len(frac))
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	n, _ := new(big.Int).SetString(digits, 10)
+	if n.Cmp(maxAmount) > 0 {
+		return nil, fmt.Errorf("amount %q is too large", s)
+	}
+	return n, nil
+}
+
+// FormatUnits formats v divided by 10^decimals exactly, without trailing zeros
+func FormatUnits(v *big.Int, decimals int) string {
+	if v == nil {
+		return "0"
+	}
+	sign := ""
+	if v.Sign() < 0 {
+		sign = "-"
+	}
+	digits := new(big.Int).Abs(v).String()
+	if decimals <= 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
+
+// FormatEther formats an amount of wei in ether
+func FormatEther(wei *big.Int) string {
+	return FormatUnits(wei, units["ether"])
+}
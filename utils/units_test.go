@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // in wei, "" for an error
+	}{
+		{"1.5ether", "1500000000000000000"},
+		{"1.5 Ether", "1500000000000000000"},
+		{"20gwei", "20000000000"},
+		{"0.1gwei", "100000000"},
+		{"300000wei", "300000"},
+		{"3kwei", "3000"},
+		{"2mwei", "2000000"},
+		{"1szabo", "1000000000000"},
+		{"1finney", "1000000000000000"},
+		{"0.000000000000000001ether", "1"},
+		{"1.000ether", "1000000000000000000"},
+		{"0", "0"},
+		{"0ether", "0"},
+		// a number without unit is ambiguous
+		{"100", ""},
+		{"1.5", ""},
+		// more decimals than the unit
+		{"0.0000000000000000001ether", ""},
+		{"1.5wei", ""},
+		{"0.1234567891gwei", ""},
+		// negative amounts
+		{"-1ether", ""},
+		{"-0", ""},
+		{"+1ether", ""},
+		// dots without digits on both sides
+		{".5ether", ""},
+		{"1.ether", ""},
+		{".ether", ""},
+		{"1..5ether", ""},
+		{"1.2.3ether", ""},
+		{"ether", ""},
+		{"", ""},
+		{"1eth", ""},
+		{"1e18wei", ""},
+		{"0x10wei", ""},
+		// the largest uint256 and one more
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639935wei", "115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639936wei", ""},
+		{"115792089237316195423570985008687907853269984665640564039458ether", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseAmount(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals int
+		want     string
+	}{
+		{"12.5", 6, "12500000"},
+		{"12.500000", 6, "12500000"},
+		{"0.000001", 6, "1"},
+		{"100", 0, "100"},
+		{"007", 2, "700"},
+		{"1.5", 0, ""},
+		{"0.0000001", 6, ""},
+		{"-12.5", 6, ""},
+		{".5", 6, ""},
+		{"5.", 6, ""},
+		{".", 6, ""},
+		{"1,5", 6, ""},
+		{" 1", 6, ""},
+		{strings.Repeat("9", 78), 0, ""},
+		{"115792089237316195423570985008687907853269984665640564039457.584007913129639935", 18, "115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+	}
+	for _, tt := range tests {
+		got, err := ParseUnits(tt.in, tt.decimals)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseUnits(%q, %d) = %s, want an error", tt.in, tt.decimals, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseUnits(%q, %d): %v", tt.in, tt.decimals, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseUnits(%q, %d) = %s, want %s", tt.in, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals int
+		want     string
+	}{
+		{"0", 18, "0"},
+		{"1", 18, "0.000000000000000001"},
+		{"1500000000000000000", 18, "1.5"},
+		{"1000000000000000000", 18, "1"},
+		{"12500000", 6, "12.5"},
+		{"100", 0, "100"},
+		{"-1500", 3, "-1.5"},
+		{"-1", 2, "-0.01"},
+	}
+	for _, tt := range tests {
+		v, _ := new(big.Int).SetString(tt.in, 10)
+		if got := FormatUnits(v, tt.decimals); got != tt.want {
+			t.Errorf("FormatUnits(%s, %d) = %s, want %s", tt.in, tt.decimals, got, tt.want)
+		}
+	}
+	if got := FormatUnits(nil, 18); got != "0" {
+		t.Errorf("FormatUnits(nil) = %s, want 0", got)
+	}
+	if got := FormatEther(big.NewInt(1e18)); got != "1" {
+		t.Errorf("FormatEther(1e18) = %s, want 1", got)
+	}
+}
+
+// TestUnitsRoundTrip parses back the formatted amounts
+func TestUnitsRoundTrip(t *testing.T) {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(10),
+		big.NewInt(123456789),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil),
+		max,
+	}
+	for _, decimals := range []int{0, 2, 6, 9, 18, 24} {
+		for _, v := range values {
+			s := FormatUnits(v, decimals)
+			got, err := ParseUnits(s, decimals)
+			if err != nil {
+				t.Errorf("ParseUnits(%q, %d): %v", s, decimals, err)
+				continue
+			}
+			if got.Cmp(v) != 0 {
+				t.Errorf("round trip of %s with %d decimals: got %s via %q", v, decimals, got, s)
+			}
+		}
+	}
+}